@@ -14,13 +14,10 @@ import (
     httpserver "backend-go/internal/http"
     "backend-go/internal/logz"
     "backend-go/internal/queue"
-    "github.com/joho/godotenv"
+    "backend-go/internal/redisx"
 )
 
 func main() {
-    // Load .env if present
-    _ = godotenv.Load(".env")
-
     // Load config
     cfg, err := config.Load()
     if err != nil {
@@ -42,7 +39,7 @@ func main() {
         Msg("config preflight")
 
     // Open DB (optional in demo, used by health)
-    dbh, err := db.Open(context.Background(), cfg)
+    dbh, err := db.Open(context.Background(), cfg, logger)
     if err != nil {
         logger.Error().Err(err).Msg("database connection failed")
     } else {
@@ -54,15 +51,23 @@ func main() {
         defer dbh.Close()
     }
 
-    // Queue client (optional but used by prompt route)
-    qClient, err := queue.NewClient(cfg)
+    // Shared Redis client, reused by the queue client and by BuildRouter for
+    // health checks, auth rate limiting, and the pub/sub-backed routes.
+    rdb, err := redisx.NewUniversalClient(cfg)
     if err != nil {
-        logger.Fatal().Err(err).Msg("failed to create queue client")
+        logger.Fatal().Err(err).Msg("failed to build redis client")
     }
+    defer rdb.Close()
+
+    // Queue client (optional but used by prompt route)
+    qClient := queue.NewClient(rdb)
     defer qClient.Close()
 
     // Build HTTP server
-    router := httpserver.BuildRouter(cfg, logger, qClient, dbh)
+    router, err := httpserver.BuildRouter(cfg, logger, rdb, qClient, dbh)
+    if err != nil {
+        logger.Fatal().Err(err).Msg("failed to build router")
+    }
 
     srv := &http.Server{
         Addr:              cfg.Address(),
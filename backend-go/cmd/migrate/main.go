@@ -2,25 +2,98 @@ package main
 
 import (
     "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io/fs"
     "log"
-    "path/filepath"
 
     "backend-go/internal/config"
     "backend-go/internal/db"
+    "backend-go/internal/logz"
     "backend-go/internal/migrate"
-    "github.com/joho/godotenv"
+    sqlfiles "backend-go/sql"
 )
 
 func main() {
-    _ = godotenv.Load(".env")
+    allowDirty := flag.Bool("allow-dirty", false, "allow re-applying a migration whose checksum has changed")
+    steps := flag.Int("steps", 1, "number of migrations to roll back (down only)")
+    flag.Parse()
+
+    args := flag.Args()
+    if len(args) == 0 {
+        log.Fatal("usage: migrate [-allow-dirty] [-steps N] {up|down|redo|status|version|force <version>}")
+    }
+
     cfg, err := config.Load()
-    if err != nil { log.Fatalf("config: %v", err) }
-    d, err := db.Open(context.Background(), cfg)
-    if err != nil { log.Fatalf("db open: %v", err) }
+    if err != nil {
+        log.Fatalf("config: %v", err)
+    }
+    logger := logz.New(cfg.LogLevel)
+    d, err := db.Open(context.Background(), cfg, logger)
+    if err != nil {
+        log.Fatalf("db open: %v", err)
+    }
     defer d.Close()
-    dir := filepath.Join("sql", "migrations")
-    if err := migrate.Up(context.Background(), d, dir); err != nil {
-        log.Fatalf("migrate up: %v", err)
+
+    src, err := fs.Sub(sqlfiles.MigrationsFS, "migrations")
+    if err != nil {
+        log.Fatalf("migrations fs: %v", err)
     }
-}
+    m := migrate.New(d, src, migrate.Options{AllowDirty: *allowDirty})
 
+    ctx := context.Background()
+    switch args[0] {
+    case "up":
+        if err := m.Up(ctx); err != nil {
+            var mismatch *migrate.ChecksumMismatchError
+            if errors.As(err, &mismatch) {
+                log.Fatalf("migrate up: %v (use -allow-dirty or `migrate force %s` to proceed)", err, mismatch.Version)
+            }
+            log.Fatalf("migrate up: %v", err)
+        }
+        log.Println("migrations applied")
+    case "down":
+        if err := m.Down(ctx, *steps); err != nil {
+            log.Fatalf("migrate down: %v", err)
+        }
+        log.Println("migrations rolled back")
+    case "redo":
+        if err := m.Redo(ctx); err != nil {
+            log.Fatalf("migrate redo: %v", err)
+        }
+        log.Println("migration redone")
+    case "status":
+        entries, err := m.Status(ctx)
+        if err != nil {
+            log.Fatalf("migrate status: %v", err)
+        }
+        for _, e := range entries {
+            state := "pending"
+            if e.Applied {
+                state = "applied " + e.AppliedAt.UTC().Format("2006-01-02T15:04:05Z07:00")
+            }
+            fmt.Printf("%s\t%s\n", e.Version, state)
+        }
+    case "version":
+        v, err := m.Version(ctx)
+        if err != nil {
+            log.Fatalf("migrate version: %v", err)
+        }
+        if v == "" {
+            fmt.Println("(no migrations applied)")
+        } else {
+            fmt.Println(v)
+        }
+    case "force":
+        if len(args) < 2 {
+            log.Fatal("usage: migrate force <version>")
+        }
+        if err := m.Force(ctx, args[1]); err != nil {
+            log.Fatalf("migrate force: %v", err)
+        }
+        log.Println("migration forced")
+    default:
+        log.Fatalf("unknown subcommand %q", args[0])
+    }
+}
@@ -8,15 +8,15 @@ import (
 
     "backend-go/internal/config"
     "backend-go/internal/db"
+    "backend-go/internal/logz"
     "backend-go/internal/repo"
-    "github.com/joho/godotenv"
 )
 
 func main() {
-    _ = godotenv.Load(".env")
     cfg, err := config.Load()
     if err != nil { log.Fatalf("config: %v", err) }
-    d, err := db.Open(context.Background(), cfg)
+    logger := logz.New(cfg.LogLevel)
+    d, err := db.Open(context.Background(), cfg, logger)
     if err != nil { log.Fatalf("db open: %v", err) }
     defer d.Close()
 
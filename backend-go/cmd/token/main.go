@@ -0,0 +1,68 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "flag"
+    "log"
+    "strings"
+
+    "backend-go/internal/config"
+    "backend-go/internal/db"
+    "backend-go/internal/logz"
+    "backend-go/internal/repo"
+    "golang.org/x/crypto/bcrypt"
+)
+
+func main() {
+    name := flag.String("name", "", "token name")
+    scopes := flag.String("scopes", "", "comma-separated scopes, e.g. sessions:read,prompts:write")
+    rateLimit := flag.Int("rate-limit", 60, "requests per minute")
+    flag.Parse()
+
+    if *name == "" || *scopes == "" {
+        log.Fatal("usage: token -name <name> -scopes <scope,scope,...> [-rate-limit N]")
+    }
+
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatalf("config: %v", err)
+    }
+    logger := logz.New(cfg.LogLevel)
+    d, err := db.Open(context.Background(), cfg, logger)
+    if err != nil {
+        log.Fatalf("db open: %v", err)
+    }
+    defer d.Close()
+
+    secret, err := randomSecret(32)
+    if err != nil {
+        log.Fatalf("generate secret: %v", err)
+    }
+    hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+    if err != nil {
+        log.Fatalf("hash secret: %v", err)
+    }
+
+    t, err := repo.CreateAPIToken(context.Background(), d, repo.CreateAPITokenInput{
+        HashedToken:        string(hashed),
+        Name:               *name,
+        Scopes:             strings.Split(*scopes, ","),
+        RateLimitPerMinute: *rateLimit,
+    })
+    if err != nil {
+        log.Fatalf("create token: %v", err)
+    }
+
+    log.Printf("minted token %q (id=%s)", t.Name, t.ID)
+    log.Printf("token (save this, it is not stored): %s.%s", t.ID, secret)
+}
+
+func randomSecret(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
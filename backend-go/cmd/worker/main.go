@@ -1,22 +1,21 @@
 package main
 
 import (
+    "context"
     "log"
     "os"
     "os/signal"
     "syscall"
 
     "backend-go/internal/config"
+    "backend-go/internal/db"
     "backend-go/internal/logz"
     "backend-go/internal/queue"
+    "backend-go/internal/redisx"
     "backend-go/internal/worker"
-    "github.com/joho/godotenv"
 )
 
 func main() {
-    // Load .env if present
-    _ = godotenv.Load(".env")
-
     cfg, err := config.Load()
     if err != nil {
         log.Fatalf("failed to load config: %v", err)
@@ -25,14 +24,25 @@ func main() {
     logger.Info().Msg("starting worker")
     logger.Info().Str("redis", cfg.RedisURL).Str("log_level", cfg.LogLevel).Msg("config preflight")
 
-    // Asynq server + mux
-    srv, mux, err := queue.NewServer(cfg, logger)
+    dbh, err := db.Open(context.Background(), cfg, logger)
     if err != nil {
-        logger.Fatal().Err(err).Msg("failed to create asynq server")
+        logger.Fatal().Err(err).Msg("database connection failed")
     }
+    defer dbh.Close()
+
+    // Shared Redis client, reused for the asynq server and, per-task, for
+    // pub/sub by the handlers registered below.
+    rdb, err := redisx.NewUniversalClient(cfg)
+    if err != nil {
+        logger.Fatal().Err(err).Msg("failed to build redis client")
+    }
+    defer rdb.Close()
+
+    // Asynq server + mux
+    srv, mux := queue.NewServer(rdb)
 
     // Register handlers
-    worker.RegisterHandlers(cfg, logger, mux)
+    worker.RegisterHandlers(cfg, logger, mux, dbh, rdb)
 
     // Start server
     go func() {
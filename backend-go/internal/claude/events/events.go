@@ -0,0 +1,181 @@
+// Package events defines the typed Claude CLI JSONL event schema so callers
+// can work with concrete structs instead of map[string]any.
+package events
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// Kind identifies the shape of an Event's payload.
+type Kind string
+
+const (
+    KindMessageStart      Kind = "message_start"
+    KindContentBlockStart Kind = "content_block_start"
+    KindTextDelta         Kind = "text_delta"
+    KindToolUse           Kind = "tool_use"
+    KindToolResult        Kind = "tool_result"
+    KindContentBlockStop  Kind = "content_block_stop"
+    KindMessageStop       Kind = "message_stop"
+    KindError             Kind = "error"
+    KindSystem            Kind = "system"
+    KindResult            Kind = "result"
+    KindCanceled          Kind = "canceled"
+)
+
+type MessageStart struct {
+    SessionID string `json:"session_id"`
+}
+
+type ContentBlockStart struct {
+    Index int `json:"idx"`
+}
+
+type TextDelta struct {
+    Text string `json:"text"`
+}
+
+type ToolUse struct {
+    Tool   string         `json:"tool"`
+    Params map[string]any `json:"params,omitempty"`
+}
+
+type ToolResult struct {
+    Tool   string `json:"tool"`
+    Result string `json:"result,omitempty"`
+    Error  string `json:"error,omitempty"`
+}
+
+type ContentBlockStop struct {
+    Index int `json:"idx"`
+}
+
+type MessageStop struct {
+    Reason string `json:"reason"`
+}
+
+type ErrorEvent struct {
+    Message string `json:"message,omitempty"`
+    Parse   string `json:"parse,omitempty"`
+}
+
+type SystemEvent struct {
+    Message string `json:"message,omitempty"`
+    Stderr  string `json:"stderr,omitempty"`
+}
+
+type ResultEvent struct {
+    Success bool   `json:"success"`
+    Error   string `json:"error,omitempty"`
+}
+
+// CanceledEvent carries no data; its presence on the stream is the signal.
+type CanceledEvent struct{}
+
+// Event is a discriminated union over the known Claude CLI event kinds. Only
+// the field matching Type is populated.
+type Event struct {
+    Type Kind
+
+    MessageStart      *MessageStart
+    ContentBlockStart *ContentBlockStart
+    TextDelta         *TextDelta
+    ToolUse           *ToolUse
+    ToolResult        *ToolResult
+    ContentBlockStop  *ContentBlockStop
+    MessageStop       *MessageStop
+    Error             *ErrorEvent
+    System            *SystemEvent
+    Result            *ResultEvent
+    Canceled          *CanceledEvent
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+    var data any
+    switch e.Type {
+    case KindMessageStart:
+        data = e.MessageStart
+    case KindContentBlockStart:
+        data = e.ContentBlockStart
+    case KindTextDelta:
+        data = e.TextDelta
+    case KindToolUse:
+        data = e.ToolUse
+    case KindToolResult:
+        data = e.ToolResult
+    case KindContentBlockStop:
+        data = e.ContentBlockStop
+    case KindMessageStop:
+        data = e.MessageStop
+    case KindError:
+        data = e.Error
+    case KindSystem:
+        data = e.System
+    case KindResult:
+        data = e.Result
+    case KindCanceled:
+        data = e.Canceled
+    default:
+        return nil, fmt.Errorf("events: unknown event type %q", e.Type)
+    }
+    return json.Marshal(struct {
+        Type Kind `json:"type"`
+        Data any  `json:"data,omitempty"`
+    }{Type: e.Type, Data: data})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+    var envelope struct {
+        Type Kind            `json:"type"`
+        Data json.RawMessage `json:"data"`
+    }
+    if err := json.Unmarshal(b, &envelope); err != nil {
+        return err
+    }
+    e.Type = envelope.Type
+    switch envelope.Type {
+    case KindMessageStart:
+        e.MessageStart = &MessageStart{}
+        return unmarshalData(envelope.Data, e.MessageStart)
+    case KindContentBlockStart:
+        e.ContentBlockStart = &ContentBlockStart{}
+        return unmarshalData(envelope.Data, e.ContentBlockStart)
+    case KindTextDelta:
+        e.TextDelta = &TextDelta{}
+        return unmarshalData(envelope.Data, e.TextDelta)
+    case KindToolUse:
+        e.ToolUse = &ToolUse{}
+        return unmarshalData(envelope.Data, e.ToolUse)
+    case KindToolResult:
+        e.ToolResult = &ToolResult{}
+        return unmarshalData(envelope.Data, e.ToolResult)
+    case KindContentBlockStop:
+        e.ContentBlockStop = &ContentBlockStop{}
+        return unmarshalData(envelope.Data, e.ContentBlockStop)
+    case KindMessageStop:
+        e.MessageStop = &MessageStop{}
+        return unmarshalData(envelope.Data, e.MessageStop)
+    case KindError:
+        e.Error = &ErrorEvent{}
+        return unmarshalData(envelope.Data, e.Error)
+    case KindSystem:
+        e.System = &SystemEvent{}
+        return unmarshalData(envelope.Data, e.System)
+    case KindResult:
+        e.Result = &ResultEvent{}
+        return unmarshalData(envelope.Data, e.Result)
+    case KindCanceled:
+        e.Canceled = &CanceledEvent{}
+        return unmarshalData(envelope.Data, e.Canceled)
+    default:
+        return fmt.Errorf("events: unknown event type %q", envelope.Type)
+    }
+}
+
+func unmarshalData(raw json.RawMessage, v any) error {
+    if len(raw) == 0 {
+        return nil
+    }
+    return json.Unmarshal(raw, v)
+}
@@ -6,18 +6,33 @@ import (
     "context"
     "encoding/json"
     "errors"
+    "io"
     "os/exec"
     "time"
 
+    "backend-go/internal/claude/events"
     "backend-go/internal/config"
     "github.com/rs/zerolog"
 )
 
 type RunOptions struct {
-    SessionID   string
-    PromptID    string
-    Prompt      string
-    ProjectPath string
+    SessionID    string
+    PromptID     string
+    Prompt       string
+    ProjectPath  string
+    AllowedTools []string // empty means no restriction
+}
+
+func (o RunOptions) toolAllowed(tool string) bool {
+    if len(o.AllowedTools) == 0 {
+        return true
+    }
+    for _, t := range o.AllowedTools {
+        if t == tool {
+            return true
+        }
+    }
+    return false
 }
 
 type Result struct {
@@ -35,7 +50,7 @@ func NewRunner(cfg *config.Config, logger zerolog.Logger) *Runner {
     return &Runner{cfg: cfg, logger: logger}
 }
 
-func (r *Runner) Run(ctx context.Context, opts RunOptions, publish func(any)) Result {
+func (r *Runner) Run(ctx context.Context, opts RunOptions, publish func(events.Event)) Result {
     // If no CLI path configured, simulate a small event stream
     if r.cfg.ClaudePath == "" {
         r.simulate(ctx, opts, publish)
@@ -47,6 +62,10 @@ func (r *Runner) Run(ctx context.Context, opts RunOptions, publish func(any)) Re
     cmd := exec.CommandContext(ctx, "node", r.cfg.ClaudePath, "query", "--json")
     cmd.Dir = opts.ProjectPath
 
+    stdin, err := cmd.StdinPipe()
+    if err != nil {
+        return Result{Success: false, Error: err.Error()}
+    }
     stdout, err := cmd.StdoutPipe()
     if err != nil {
         return Result{Success: false, Error: err.Error()}
@@ -64,25 +83,24 @@ func (r *Runner) Run(ctx context.Context, opts RunOptions, publish func(any)) Re
     go func() {
         s := bufio.NewScanner(stderr)
         for s.Scan() {
-            publish(map[string]any{"type": "system", "data": map[string]any{"stderr": s.Text()}})
+            publish(events.Event{Type: events.KindSystem, System: &events.SystemEvent{Stderr: s.Text()}})
         }
     }()
 
-    // stream stdout JSONL
+    // stream stdout JSONL, decoding strictly into the known event schema
     scan := bufio.NewScanner(stdout)
     for scan.Scan() {
         line := bytes.TrimSpace(scan.Bytes())
         if len(line) == 0 {
             continue
         }
-        // In a real implementation, define precise structs and decode strictly
-        var raw map[string]any
-        if err := json.Unmarshal(line, &raw); err != nil {
+        var ev events.Event
+        if err := json.Unmarshal(line, &ev); err != nil {
             // publish parse error but continue
-            publish(map[string]any{"type": "error", "data": map[string]any{"parse": err.Error()}})
+            publish(events.Event{Type: events.KindError, Error: &events.ErrorEvent{Parse: err.Error()}})
             continue
         }
-        publish(raw)
+        publishEnforcingAllowlist(opts, ev, publish, stdin)
     }
 
     if err := cmd.Wait(); err != nil {
@@ -96,17 +114,34 @@ func (r *Runner) Run(ctx context.Context, opts RunOptions, publish func(any)) Re
     return Result{Success: true}
 }
 
-func (r *Runner) simulate(ctx context.Context, opts RunOptions, publish func(any)) {
+func (r *Runner) simulate(ctx context.Context, opts RunOptions, publish func(events.Event)) {
     // Minimal fake streaming events for demo purposes
-    publish(map[string]any{"type": "message_start", "data": map[string]any{"session_id": opts.SessionID}})
-    publish(map[string]any{"type": "content_block_start", "data": map[string]any{"idx": 0}})
-    publish(map[string]any{"type": "text_delta", "data": map[string]any{"text": "Working on: " + opts.Prompt}})
+    publish(events.Event{Type: events.KindMessageStart, MessageStart: &events.MessageStart{SessionID: opts.SessionID}})
+    publish(events.Event{Type: events.KindContentBlockStart, ContentBlockStart: &events.ContentBlockStart{Index: 0}})
+    publish(events.Event{Type: events.KindTextDelta, TextDelta: &events.TextDelta{Text: "Working on: " + opts.Prompt}})
     time.Sleep(300 * time.Millisecond)
-    publish(map[string]any{"type": "tool_use", "data": map[string]any{"tool": "bash", "params": map[string]any{"command": "ls -la"}}})
+    publishEnforcingAllowlist(opts, events.Event{Type: events.KindToolUse, ToolUse: &events.ToolUse{Tool: "bash", Params: map[string]any{"command": "ls -la"}}}, publish, nil)
     time.Sleep(300 * time.Millisecond)
-    publish(map[string]any{"type": "tool_result", "data": map[string]any{"tool": "bash", "result": "README.md\nmain.go"}})
+    if opts.toolAllowed("bash") {
+        publish(events.Event{Type: events.KindToolResult, ToolResult: &events.ToolResult{Tool: "bash", Result: "README.md\nmain.go"}})
+    }
     time.Sleep(300 * time.Millisecond)
-    publish(map[string]any{"type": "content_block_stop", "data": map[string]any{"idx": 0}})
-    publish(map[string]any{"type": "message_stop", "data": map[string]any{"reason": "end_turn"}})
+    publish(events.Event{Type: events.KindContentBlockStop, ContentBlockStop: &events.ContentBlockStop{Index: 0}})
+    publish(events.Event{Type: events.KindMessageStop, MessageStop: &events.MessageStop{Reason: "end_turn"}})
+}
+
+// publishEnforcingAllowlist drops tool_use events for tools outside
+// opts.AllowedTools, synthesizing a tool_result error in their place instead
+// of forwarding them to the client. When stdin is non-nil (the real CLI
+// path) it also sends a cancel signal so the tool doesn't actually execute.
+func publishEnforcingAllowlist(opts RunOptions, ev events.Event, publish func(events.Event), stdin io.WriteCloser) {
+    if ev.Type == events.KindToolUse && ev.ToolUse != nil && !opts.toolAllowed(ev.ToolUse.Tool) {
+        publish(events.Event{Type: events.KindToolResult, ToolResult: &events.ToolResult{Tool: ev.ToolUse.Tool, Error: "tool not allowed"}})
+        if stdin != nil {
+            _, _ = stdin.Write([]byte(`{"type":"cancel"}` + "\n"))
+        }
+        return
+    }
+    publish(ev)
 }
 
@@ -6,14 +6,20 @@ import (
     "time"
 
     "backend-go/internal/config"
+    "backend-go/internal/logz"
     "github.com/jackc/pgx/v5/pgxpool"
+    "github.com/jackc/pgx/v5/tracelog"
+    "github.com/rs/zerolog"
 )
 
 type DB struct {
     Pool *pgxpool.Pool
 }
 
-func Open(ctx context.Context, cfg *config.Config) (*DB, error) {
+// Open connects to cfg's database and wires pgx's query tracer to log
+// through logger, so slow or failing queries show up with the same
+// request_id/fields as everything else this process logs.
+func Open(ctx context.Context, cfg *config.Config, logger zerolog.Logger) (*DB, error) {
     dsn := cfg.DatabaseURL
     if dsn == "" {
         dsn = cfg.DatabaseDSN()
@@ -28,6 +34,11 @@ func Open(ctx context.Context, cfg *config.Config) (*DB, error) {
     conf.MaxConnLifetime = time.Hour
     conf.MaxConnIdleTime = 15 * time.Minute
 
+    conf.ConnConfig.Tracer = &tracelog.TraceLog{
+        Logger:   zerologAdapter{logger: logger.With().Str("component", "pgx").Logger()},
+        LogLevel: pgxLogLevel(logger.GetLevel()),
+    }
+
     pool, err := pgxpool.NewWithConfig(ctx, conf)
     if err != nil {
         return nil, fmt.Errorf("open db: %w", err)
@@ -53,3 +64,52 @@ func (d *DB) Ping(ctx context.Context) error {
     return nil
 }
 
+// zerologAdapter lets a zerolog.Logger satisfy pgx's tracelog.Logger
+// interface, so pool/query events land in the same structured log stream as
+// the rest of the service.
+type zerologAdapter struct {
+    logger zerolog.Logger
+}
+
+func (a zerologAdapter) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+    // Prefer the request-scoped logger (carrying request_id) when the query
+    // runs under a context that RequestLogger attached one to.
+    logger := a.logger
+    if reqLogger := logz.FromContext(ctx); reqLogger.GetLevel() != zerolog.Disabled {
+        logger = reqLogger.With().Str("component", "pgx").Logger()
+    }
+    var event *zerolog.Event
+    switch level {
+    case tracelog.LogLevelTrace:
+        event = logger.Trace()
+    case tracelog.LogLevelDebug:
+        event = logger.Debug()
+    case tracelog.LogLevelInfo:
+        event = logger.Info()
+    case tracelog.LogLevelWarn:
+        event = logger.Warn()
+    case tracelog.LogLevelError:
+        event = logger.Error()
+    default:
+        event = logger.Info()
+    }
+    for k, v := range data {
+        event = event.Interface(k, v)
+    }
+    event.Msg(msg)
+}
+
+func pgxLogLevel(l zerolog.Level) tracelog.LogLevel {
+    switch l {
+    case zerolog.TraceLevel:
+        return tracelog.LogLevelTrace
+    case zerolog.DebugLevel:
+        return tracelog.LogLevelDebug
+    case zerolog.InfoLevel:
+        return tracelog.LogLevelInfo
+    case zerolog.WarnLevel:
+        return tracelog.LogLevelWarn
+    default:
+        return tracelog.LogLevelError
+    }
+}
@@ -0,0 +1,163 @@
+// Package health provides a small dependency-check registry: each Checker
+// reports whether one downstream dependency (database, redis, ...) is
+// reachable, and a Registry fans the checks out in parallel with a per-check
+// timeout, caching each result for a short TTL so a readiness probe hit
+// every few seconds doesn't re-dial every dependency on every request.
+package health
+
+import (
+    "context"
+    "errors"
+    "runtime/debug"
+    "sync"
+    "time"
+)
+
+// Checker reports the health of a single dependency. Returning a
+// *DegradedError (see Degraded) marks the dependency as up but impaired,
+// rather than fully down.
+type Checker interface {
+    Name() string
+    Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function to the Checker interface.
+type CheckerFunc struct {
+    CheckerName string
+    Fn          func(ctx context.Context) error
+}
+
+func (f CheckerFunc) Name() string                    { return f.CheckerName }
+func (f CheckerFunc) Check(ctx context.Context) error { return f.Fn(ctx) }
+
+// DegradedError marks a Checker failure as degraded rather than fully
+// unhealthy: the dependency still basically works (e.g. elevated disk usage
+// or a growing but not yet alarming queue backlog), but it deserves
+// attention before it becomes an outage.
+type DegradedError struct{ err error }
+
+// Degraded wraps err so a Checker can report degraded rather than unhealthy.
+// Degraded(nil) returns nil.
+func Degraded(err error) error {
+    if err == nil {
+        return nil
+    }
+    return &DegradedError{err: err}
+}
+
+func (e *DegradedError) Error() string { return e.err.Error() }
+func (e *DegradedError) Unwrap() error { return e.err }
+
+// Status is the cached or freshly-run result of one Checker.
+type Status struct {
+    Name        string
+    Healthy     bool // true for both a clean pass and a degraded one
+    Degraded    bool
+    Error       string
+    Latency     time.Duration
+    CheckedAt   time.Time
+    LastSuccess time.Time // zero if the checker has never once succeeded
+}
+
+// Registry runs a fixed set of Checkers, fanning them out in parallel and
+// caching each result for ttl so repeated probes stay cheap.
+type Registry struct {
+    checkers  []Checker
+    timeout   time.Duration
+    ttl       time.Duration
+    startedAt time.Time
+
+    mu          sync.Mutex
+    cache       map[string]Status
+    lastSuccess map[string]time.Time
+}
+
+// NewRegistry builds a Registry. timeout bounds each individual check;
+// ttl controls how long a result is reused before the check is re-run.
+func NewRegistry(timeout, ttl time.Duration, checkers ...Checker) *Registry {
+    return &Registry{
+        checkers:    checkers,
+        timeout:     timeout,
+        ttl:         ttl,
+        startedAt:   time.Now(),
+        cache:       make(map[string]Status),
+        lastSuccess: make(map[string]time.Time),
+    }
+}
+
+// Check runs (or reuses cached results for) every registered Checker in
+// parallel and returns one Status per checker, in registration order.
+func (r *Registry) Check(ctx context.Context) []Status {
+    results := make([]Status, len(r.checkers))
+    var wg sync.WaitGroup
+    for i, c := range r.checkers {
+        wg.Add(1)
+        go func(i int, c Checker) {
+            defer wg.Done()
+            results[i] = r.checkOne(ctx, c)
+        }(i, c)
+    }
+    wg.Wait()
+    return results
+}
+
+func (r *Registry) checkOne(ctx context.Context, c Checker) Status {
+    r.mu.Lock()
+    cached, ok := r.cache[c.Name()]
+    r.mu.Unlock()
+    if ok && time.Since(cached.CheckedAt) < r.ttl {
+        return cached
+    }
+
+    cctx, cancel := context.WithTimeout(ctx, r.timeout)
+    defer cancel()
+    start := time.Now()
+    err := c.Check(cctx)
+
+    status := Status{Name: c.Name(), CheckedAt: time.Now(), Latency: time.Since(start)}
+    var degraded *DegradedError
+    switch {
+    case err == nil:
+        status.Healthy = true
+    case errors.As(err, &degraded):
+        status.Healthy = true
+        status.Degraded = true
+        status.Error = degraded.Error()
+    default:
+        status.Error = err.Error()
+    }
+
+    r.mu.Lock()
+    if status.Healthy {
+        r.lastSuccess[c.Name()] = status.CheckedAt
+    }
+    status.LastSuccess = r.lastSuccess[c.Name()]
+    r.cache[c.Name()] = status
+    r.mu.Unlock()
+    return status
+}
+
+// Uptime reports how long this Registry (and, in practice, the process)
+// has been running.
+func (r *Registry) Uptime() time.Duration { return time.Since(r.startedAt) }
+
+// Version is set at build time via -ldflags "-X backend-go/internal/health.Version=...".
+// When left at its default it falls back to the VCS revision embedded by
+// the Go toolchain, so a dev build still reports something useful.
+var Version = "dev"
+
+// BuildVersion returns Version, or the VCS revision from the build info if
+// Version was never set via ldflags.
+func BuildVersion() string {
+    if Version != "dev" {
+        return Version
+    }
+    if info, ok := debug.ReadBuildInfo(); ok {
+        for _, s := range info.Settings {
+            if s.Key == "vcs.revision" {
+                return s.Value
+            }
+        }
+    }
+    return Version
+}
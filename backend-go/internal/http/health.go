@@ -2,64 +2,87 @@ package http
 
 import (
     "context"
+    "errors"
+    "fmt"
     "net/http"
+    "os"
+    "syscall"
     "time"
 
     "backend-go/internal/config"
     "backend-go/internal/db"
+    "backend-go/internal/health"
     "github.com/gin-gonic/gin"
+    "github.com/hibiken/asynq"
     redis "github.com/redis/go-redis/v9"
 )
 
-func RegisterHealthRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
-    r.GET("/health", func(c *gin.Context) {
-        ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
-        defer cancel()
+const (
+    healthCheckTimeout = 2 * time.Second
+    healthCheckTTL     = 5 * time.Second
 
-        services := map[string]string{
-            "redis": "unknown",
-            "database": "unknown",
-        }
+    // diskFreeWarnPercent degrades the "disk" checker once free space on
+    // cfg.ReposDir drops below this percentage of total capacity.
+    diskFreeWarnPercent = 10.0
+    // queueBacklogWarnDepth degrades the "queue" checker once the default
+    // asynq queue's pending+scheduled+retry count grows past this.
+    queueBacklogWarnDepth = 100
+)
 
-        // Check Redis
-        rdb := redis.NewClient(&redis.Options{Addr: redisAddrFromURL(cfg.RedisURL)})
-        if err := rdb.Ping(ctx).Err(); err != nil {
-            services["redis"] = "unhealthy"
-        } else {
-            services["redis"] = "healthy"
-        }
-        _ = rdb.Close()
-
-        // Check DB if available
-        if dbh != nil {
-            if err := dbh.Ping(ctx); err != nil {
-                services["database"] = "unhealthy"
-            } else {
-                services["database"] = "healthy"
+// RegisterHealthRoutes wires /health (full dependency report, with a
+// degraded/unhealthy distinction and per-component latency and last-success
+// timestamp), /live (liveness: the process is up and serving), /ready
+// (readiness: every registered dependency is at least degraded-but-healthy),
+// and /startup (startup probe: the same every-dependency-healthy-right-now
+// check as /ready — meant to be polled only until it first succeeds, unlike
+// /live and /ready which are polled for the life of the process).
+func RegisterHealthRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB, rdb redis.UniversalClient) {
+    insp := asynq.NewInspectorFromRedisClient(rdb)
+
+    registry := health.NewRegistry(healthCheckTimeout, healthCheckTTL,
+        health.CheckerFunc{CheckerName: "redis", Fn: func(ctx context.Context) error { return rdb.Ping(ctx).Err() }},
+        health.CheckerFunc{CheckerName: "database", Fn: dbh.Ping},
+        health.CheckerFunc{CheckerName: "claude_binary", Fn: func(ctx context.Context) error { return checkClaudeBinary(cfg.ClaudePath) }},
+        health.CheckerFunc{CheckerName: "disk", Fn: func(ctx context.Context) error { return checkDiskSpace(cfg.ReposDir) }},
+        health.CheckerFunc{CheckerName: "queue", Fn: func(ctx context.Context) error { return checkQueueBacklog(insp) }},
+    )
+
+    r.GET("/health", func(c *gin.Context) {
+        statuses := registry.Check(c.Request.Context())
+        services := make(gin.H, len(statuses))
+        overall := "healthy"
+        for _, s := range statuses {
+            comp := gin.H{
+                "status":    componentStatus(s),
+                "latencyMs": s.Latency.Milliseconds(),
             }
-        } else {
-            services["database"] = "unhealthy"
-        }
+            if !s.LastSuccess.IsZero() {
+                comp["lastSuccess"] = s.LastSuccess.UTC().Format(time.RFC3339)
+            }
+            if s.Error != "" {
+                comp["error"] = s.Error
+            }
+            services[s.Name] = comp
 
-        allHealthy := true
-        for _, v := range services {
-            if v != "healthy" {
-                allHealthy = false
-                break
+            switch {
+            case !s.Healthy:
+                overall = "unhealthy"
+            case s.Degraded && overall != "unhealthy":
+                overall = "degraded"
             }
         }
 
         status := http.StatusOK
-        if !allHealthy {
+        if overall == "unhealthy" {
             status = http.StatusServiceUnavailable
         }
 
         c.JSON(status, gin.H{
-            "status":   map[bool]string{true: "healthy", false: "unhealthy"}[allHealthy],
+            "status":    overall,
             "timestamp": time.Now().UTC().Format(time.RFC3339),
-            "services": services,
-            "version":  "0.1.0",
-            "uptime":   0, // left simple in demo
+            "services":  services,
+            "version":   health.BuildVersion(),
+            "uptime":    registry.Uptime().Seconds(),
         })
     })
 
@@ -68,16 +91,98 @@ func RegisterHealthRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
     })
 
     r.GET("/ready", func(c *gin.Context) {
-        c.JSON(http.StatusOK, gin.H{"status": "ready", "timestamp": time.Now().UTC().Format(time.RFC3339)})
+        checkAllHealthy(c, registry, "not ready", "ready")
+    })
+
+    r.GET("/startup", func(c *gin.Context) {
+        checkAllHealthy(c, registry, "starting", "started")
     })
 }
 
-// redisAddrFromURL converts a redis://host:port URL to host:port form for go-redis simple demo.
-func redisAddrFromURL(url string) string {
-    // Minimal: support redis://host:port only
-    const prefix = "redis://"
-    if len(url) > len(prefix) && url[:len(prefix)] == prefix {
-        return url[len(prefix):]
+// checkAllHealthy runs every registered check and writes a 503 with
+// notReadyStatus (naming the first unhealthy dependency) if any of them
+// failed outright, or a 200 with readyStatus otherwise. /ready and /startup
+// share this: they ask the same question ("is everything at least
+// degraded-but-healthy right now?"), just at different points in the
+// process lifecycle.
+func checkAllHealthy(c *gin.Context, registry *health.Registry, notReadyStatus, readyStatus string) {
+    statuses := registry.Check(c.Request.Context())
+    for _, s := range statuses {
+        if !s.Healthy {
+            c.JSON(http.StatusServiceUnavailable, gin.H{"status": notReadyStatus, "reason": s.Name, "error": s.Error})
+            return
+        }
+    }
+    c.JSON(http.StatusOK, gin.H{"status": readyStatus, "timestamp": time.Now().UTC().Format(time.RFC3339)})
+}
+
+// componentStatus renders a Status as the three-state string /health reports
+// per component.
+func componentStatus(s health.Status) string {
+    switch {
+    case !s.Healthy:
+        return "unhealthy"
+    case s.Degraded:
+        return "degraded"
+    default:
+        return "healthy"
+    }
+}
+
+// checkClaudeBinary reports whether cfg.ClaudePath, if set, points at an
+// executable file. An unset path means the runner is in demo/simulate mode,
+// which isn't a health problem.
+func checkClaudeBinary(path string) error {
+    if path == "" {
+        return nil
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("claude binary: %w", err)
+    }
+    if info.Mode()&0o111 == 0 {
+        return fmt.Errorf("claude binary %q is not executable", path)
+    }
+    return nil
+}
+
+// checkDiskSpace degrades once free space on dir drops below
+// diskFreeWarnPercent, and fails outright if dir can't be statted at all.
+func checkDiskSpace(dir string) error {
+    var stat syscall.Statfs_t
+    if err := syscall.Statfs(dir, &stat); err != nil {
+        return fmt.Errorf("disk space: %w", err)
+    }
+    total := float64(stat.Blocks) * float64(stat.Bsize)
+    if total == 0 {
+        return nil
+    }
+    free := float64(stat.Bavail) * float64(stat.Bsize)
+    freePercent := free / total * 100
+    if freePercent < diskFreeWarnPercent {
+        return health.Degraded(fmt.Errorf("only %.1f%% free space left on %s", freePercent, dir))
+    }
+    return nil
+}
+
+// checkQueueBacklog degrades once the default asynq queue's outstanding
+// (pending + scheduled + retry) task count grows past queueBacklogWarnDepth.
+// The "default" queue isn't registered in Redis until the worker process has
+// started and pulled or enqueued at least one task, so a fresh deployment
+// (API up, worker not yet up, or nothing enqueued yet) sees ErrQueueNotFound
+// here; that's not a queue problem, so it's treated as healthy rather than
+// failing /startup forever waiting on an independent process.
+func checkQueueBacklog(insp *asynq.Inspector) error {
+    info, err := insp.GetQueueInfo("default")
+    if errors.Is(err, asynq.ErrQueueNotFound) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("queue backlog: %w", err)
+    }
+    backlog := info.Pending + info.Scheduled + info.Retry
+    if backlog > queueBacklogWarnDepth {
+        return health.Degraded(fmt.Errorf("queue backlog is %d (> %d)", backlog, queueBacklogWarnDepth))
     }
-    return url
+    return nil
 }
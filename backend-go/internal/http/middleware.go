@@ -0,0 +1,71 @@
+package http
+
+import (
+    "net/http"
+    "runtime/debug"
+    "time"
+
+    "backend-go/internal/logz"
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/rs/zerolog"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestLogger generates (or propagates) an X-Request-ID, attaches a child
+// logger carrying it plus the method/path to the request context so
+// downstream handlers can pull it via logz.FromContext, and logs one line
+// per request at a level based on the response status.
+func RequestLogger(base zerolog.Logger) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        requestID := c.GetHeader(requestIDHeader)
+        if requestID == "" {
+            requestID = uuid.New().String()
+        }
+        c.Writer.Header().Set(requestIDHeader, requestID)
+
+        logger := base.With().
+            Str("request_id", requestID).
+            Str("method", c.Request.Method).
+            Str("path", c.Request.URL.Path).
+            Str("remote_ip", c.ClientIP()).
+            Str("user_agent", c.Request.UserAgent()).
+            Logger()
+        c.Request = c.Request.WithContext(logz.WithContext(c.Request.Context(), logger))
+
+        start := time.Now()
+        c.Next()
+        latency := time.Since(start)
+
+        status := c.Writer.Status()
+        var event *zerolog.Event
+        switch {
+        case status >= http.StatusInternalServerError:
+            event = logger.Error()
+        case status >= http.StatusBadRequest:
+            event = logger.Warn()
+        default:
+            event = logger.Info()
+        }
+        event.Int("status", status).Dur("latency", latency).Int("bytes", c.Writer.Size()).Msg("request")
+    }
+}
+
+// Recovery replaces gin's default panic recovery: it logs the panic value
+// and a stack trace through the request's logger (so it carries the same
+// request_id as everything else) before returning a bare 500.
+func Recovery() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        defer func() {
+            if rec := recover(); rec != nil {
+                logz.FromContext(c.Request.Context()).Error().
+                    Interface("panic", rec).
+                    Str("stack", string(debug.Stack())).
+                    Msg("panic recovered")
+                c.AbortWithStatus(http.StatusInternalServerError)
+            }
+        }()
+        c.Next()
+    }
+}
@@ -1,12 +1,20 @@
 package http
 
 import (
+    "encoding/json"
+    "fmt"
     "net/http"
+    "strconv"
+    "time"
 
+    "backend-go/internal/auth"
     "backend-go/internal/config"
+    "backend-go/internal/db"
     "backend-go/internal/queue"
+    "backend-go/internal/repo"
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
+    redis "github.com/redis/go-redis/v9"
     "github.com/rs/zerolog"
 )
 
@@ -15,10 +23,16 @@ type createPromptBody struct {
     ProjectPath string `json:"projectPath"`
 }
 
-func RegisterPromptRoutes(r *gin.Engine, cfg *config.Config, logger zerolog.Logger, q *queue.Client) {
+// RegisterPromptRoutes wires the prompt enqueue/cancel/stream API. rdb is the
+// process's shared Redis client, reused here for auth rate limiting, the
+// cancel control-channel publish, and the event stream subscribe, instead of
+// dialing a new client per request.
+func RegisterPromptRoutes(r *gin.Engine, cfg *config.Config, logger zerolog.Logger, q *queue.Client, dbh *db.DB, rdb redis.UniversalClient) {
     group := r.Group("/api/claude-code/sessions/:sessionId/prompts")
+    requireRead := auth.Middleware(dbh, rdb, auth.ScopeSessionsRead)
+    requireWrite := auth.Middleware(dbh, rdb, auth.ScopePromptsWrite)
 
-    group.POST("/", func(c *gin.Context) {
+    group.POST("/", requireWrite, func(c *gin.Context) {
         sessionID := c.Param("sessionId")
         var body createPromptBody
         if err := c.ShouldBindJSON(&body); err != nil {
@@ -51,5 +65,181 @@ func RegisterPromptRoutes(r *gin.Engine, cfg *config.Config, logger zerolog.Logg
             "sessionId": sessionID,
         })
     })
+
+    group.GET("/:promptId/stream", requireRead, func(c *gin.Context) {
+        sessionID := c.Param("sessionId")
+        promptID := c.Param("promptId")
+        streamPrompt(c, rdb, logger, sessionID, promptID)
+    })
+
+    group.DELETE("/:promptId", requireWrite, func(c *gin.Context) {
+        sessionID := c.Param("sessionId")
+        promptID := c.Param("promptId")
+
+        if err := rdb.Publish(c.Request.Context(), controlChannel(sessionID, promptID), "cancel").Err(); err != nil {
+            logger.Error().Err(err).Msg("publish cancel failed")
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "cancel failed"})
+            return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"success": true, "message": "Cancellation requested"})
+    })
+
+    group.GET("/:promptId/messages", requireRead, func(c *gin.Context) {
+        sessionID, err := uuid.Parse(c.Param("sessionId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+            return
+        }
+        promptID := c.Param("promptId")
+        messages, err := repo.ListMessages(c.Request.Context(), dbh, sessionID, promptID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        out := make([]gin.H, 0, len(messages))
+        for _, m := range messages {
+            out = append(out, gin.H{
+                "seq":       m.Seq,
+                "type":      m.Type,
+                "event":     jsonRawOrNil(m.Payload),
+                "createdAt": m.CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"),
+            })
+        }
+        c.JSON(http.StatusOK, gin.H{"messages": out})
+    })
+}
+
+// streamPrompt subscribes to the Redis pubsub channel a worker publishes
+// Claude events on and re-emits each event as an SSE frame, replaying any
+// events the client missed (per Last-Event-ID) first. The logKey list, not
+// the pubsub payload, is the source of truth for what to emit: pubsub
+// messages are only ever used as a "something was appended, go re-read the
+// list" wake-up, so every event is read from the list exactly once no
+// matter how the subscribe/append race falls, instead of risking the event
+// being dropped (subscribed too late) or shown twice (subscribed too early
+// and also replayed).
+func streamPrompt(c *gin.Context, rdb redis.UniversalClient, logger zerolog.Logger, sessionID, promptID string) {
+    channel := promptChannel(sessionID, promptID)
+    logKey := channel + ":log"
+
+    c.Writer.Header().Set("Content-Type", "text/event-stream")
+    c.Writer.Header().Set("Cache-Control", "no-cache")
+    c.Writer.Header().Set("Connection", "keep-alive")
+    c.Writer.WriteHeader(http.StatusOK)
+
+    flusher, ok := c.Writer.(http.Flusher)
+    if !ok {
+        logger.Error().Msg("streaming not supported by response writer")
+        return
+    }
+
+    ctx := c.Request.Context()
+
+    // Subscribe before computing the starting index below, so a publish
+    // landing in that gap still wakes up a re-read of the list instead of
+    // being missed.
+    sub := rdb.Subscribe(ctx, channel)
+    defer sub.Close()
+    msgCh := sub.Channel()
+
+    // next is the next 0-based logKey index to emit.
+    var next int64
+    if lastIDStr := c.GetHeader("Last-Event-ID"); lastIDStr != "" {
+        lastSeq, _ := strconv.ParseInt(lastIDStr, 10, 64)
+        next = lastSeq + 1
+    } else {
+        n, err := rdb.LLen(ctx, logKey).Result()
+        if err != nil {
+            // Can't reliably tell "start of history" from "current tail"
+            // without this, so bail rather than risk replaying the whole
+            // log to a client that only asked to tail new events.
+            logger.Error().Err(err).Msg("replay log length fetch failed")
+            return
+        }
+        next = n
+    }
+
+    // drain emits every entry from next to the end of the list and reports
+    // whether a terminal event was among them.
+    drain := func() bool {
+        entries, err := rdb.LRange(ctx, logKey, next, -1).Result()
+        if err != nil {
+            logger.Error().Err(err).Msg("replay log fetch failed")
+            return false
+        }
+        terminal := false
+        for _, raw := range entries {
+            writeSSEEvent(c.Writer, int(next), raw)
+            next++
+            if isTerminalEvent(raw) {
+                terminal = true
+            }
+        }
+        if len(entries) > 0 {
+            flusher.Flush()
+        }
+        return terminal
+    }
+
+    if drain() {
+        return
+    }
+
+    keepalive := time.NewTicker(15 * time.Second)
+    defer keepalive.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case _, ok := <-msgCh:
+            if !ok {
+                return
+            }
+            if drain() {
+                return
+            }
+        case <-keepalive.C:
+            if drain() {
+                return
+            }
+            fmt.Fprint(c.Writer, ": keepalive\n\n")
+            flusher.Flush()
+        }
+    }
+}
+
+func writeSSEEvent(w http.ResponseWriter, seq int, payload string) {
+    fmt.Fprintf(w, "id: %d\n", seq)
+    fmt.Fprintf(w, "event: %s\n", eventType(payload))
+    fmt.Fprintf(w, "data: %s\n\n", payload)
+}
+
+func eventType(payload string) string {
+    var envelope struct {
+        Type string `json:"type"`
+    }
+    if err := json.Unmarshal([]byte(payload), &envelope); err != nil || envelope.Type == "" {
+        return "message"
+    }
+    return envelope.Type
+}
+
+func isTerminalEvent(payload string) bool {
+    switch eventType(payload) {
+    case "result", "error", "canceled":
+        return true
+    default:
+        return false
+    }
+}
+
+func promptChannel(sessionID, promptID string) string {
+    return "claude-code:" + sessionID + ":" + promptID
+}
+
+func controlChannel(sessionID, promptID string) string {
+    return promptChannel(sessionID, promptID) + ":control"
 }
 
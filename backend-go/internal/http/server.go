@@ -1,6 +1,7 @@
 package http
 
 import (
+    "fmt"
     "net/http"
     "time"
 
@@ -10,15 +11,19 @@ import (
 
     "github.com/gin-contrib/cors"
     "github.com/gin-gonic/gin"
+    redis "github.com/redis/go-redis/v9"
     "github.com/rs/zerolog"
 )
 
-// BuildRouter constructs the Gin engine with routes and middleware.
-func BuildRouter(cfg *config.Config, logger zerolog.Logger, q *queue.Client, dbh *db.DB) *gin.Engine {
+// BuildRouter constructs the Gin engine with routes and middleware. rdb is
+// the process's single long-lived Redis client (see redisx.NewUniversalClient),
+// shared across auth rate limiting, health checks, and every pub/sub-backed
+// route instead of each one dialing its own.
+func BuildRouter(cfg *config.Config, logger zerolog.Logger, rdb redis.UniversalClient, q *queue.Client, dbh *db.DB) (*gin.Engine, error) {
     gin.SetMode(gin.ReleaseMode)
     r := gin.New()
-    r.Use(gin.Recovery())
-    r.Use(gin.Logger())
+    r.Use(RequestLogger(logger))
+    r.Use(Recovery())
 
     r.Use(cors.New(cors.Config{
         AllowAllOrigins: true,
@@ -27,12 +32,15 @@ func BuildRouter(cfg *config.Config, logger zerolog.Logger, q *queue.Client, dbh
         MaxAge:           12 * time.Hour,
     }))
 
-    // Health endpoints
-    RegisterHealthRoutes(r, cfg, dbh)
+    // Health endpoints (unauthenticated)
+    RegisterHealthRoutes(r, cfg, dbh, rdb)
 
     // Sessions + prompts routes (demo)
-    RegisterSessionRoutes(r, cfg, dbh)
-    RegisterPromptRoutes(r, cfg, logger, q)
+    RegisterSessionRoutes(r, cfg, dbh, rdb)
+    RegisterPromptRoutes(r, cfg, logger, q, dbh, rdb)
+    if err := RegisterUploadRoutes(r, cfg, dbh, rdb); err != nil {
+        return nil, fmt.Errorf("build router: %w", err)
+    }
 
     // Root
     r.GET("/", func(c *gin.Context) {
@@ -44,5 +52,5 @@ func BuildRouter(cfg *config.Config, logger zerolog.Logger, q *queue.Client, dbh
         })
     })
 
-    return r
+    return r, nil
 }
@@ -1,16 +1,20 @@
 package http
 
 import (
+    "errors"
     "fmt"
     "encoding/json"
     "net/http"
     "path/filepath"
+    "strings"
 
+    "backend-go/internal/auth"
     "backend-go/internal/config"
     "backend-go/internal/db"
     "backend-go/internal/repo"
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
+    redis "github.com/redis/go-redis/v9"
 )
 
 type createSessionBody struct {
@@ -21,15 +25,26 @@ type createSessionBody struct {
 }
 
 type updateSessionBody struct {
-    Context *string        `json:"context"`
-    Status  *string        `json:"status"`
-    Metadata map[string]any `json:"metadata"`
+    Context *string `json:"context"`
+    Status  *string `json:"status"`
+
+    // Metadata is applied as an RFC 7396 merge patch; JSONPatch, if given
+    // instead, is applied as an RFC 6902 JSON Patch operation array. At
+    // most one of the two may be set.
+    Metadata  map[string]any   `json:"metadata"`
+    JSONPatch []map[string]any `json:"jsonPatch"`
+
+    // ExpectedVersion enables optimistic concurrency: if set and it
+    // doesn't match the session's current version, the update is rejected.
+    ExpectedVersion *int64 `json:"expectedVersion"`
 }
 
-func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
+func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB, rdb redis.UniversalClient) {
     group := r.Group("/api/claude-code/sessions")
+    requireRead := auth.Middleware(dbh, rdb, auth.ScopeSessionsRead)
+    requireWrite := auth.Middleware(dbh, rdb, auth.ScopeSessionsWrite)
 
-    group.POST("/", func(c *gin.Context) {
+    group.POST("/", requireWrite, func(c *gin.Context) {
         var body createSessionBody
         if err := c.ShouldBindJSON(&body); err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -59,7 +74,7 @@ func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
         c.JSON(http.StatusCreated, toSessionResponse(s))
     })
 
-    group.GET("/", func(c *gin.Context) {
+    group.GET("/", requireRead, func(c *gin.Context) {
         // optional status, limit, offset
         status := c.Query("status")
         var stp *string
@@ -73,7 +88,7 @@ func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
         c.JSON(http.StatusOK, gin.H{"sessions": out, "total": total, "limit": limit, "offset": offset})
     })
 
-    group.GET("/:sessionId", func(c *gin.Context) {
+    group.GET("/:sessionId", requireRead, func(c *gin.Context) {
         idStr := c.Param("sessionId")
         id, err := uuid.Parse(idStr)
         if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"}); return }
@@ -82,18 +97,57 @@ func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
         c.JSON(http.StatusOK, toSessionResponse(s))
     })
 
-    group.PATCH("/:sessionId", func(c *gin.Context) {
+    group.PATCH("/:sessionId", requireWrite, func(c *gin.Context) {
         id, err := uuid.Parse(c.Param("sessionId"))
         if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"}); return }
         var body updateSessionBody
         if err := c.ShouldBindJSON(&body); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
-        metaBytes, _ := json.Marshal(body.Metadata)
-        s, err := repo.UpdateSession(c.Request.Context(), dbh, id, repo.UpdateSessionInput{Context: body.Context, Status: body.Status, Metadata: metaBytes})
-        if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"}); return }
+        if body.Metadata != nil && body.JSONPatch != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "cannot provide both metadata and jsonPatch"})
+            return
+        }
+
+        in := repo.UpdateSessionInput{Context: body.Context, Status: body.Status, ExpectedVersion: body.ExpectedVersion}
+        switch {
+        case body.JSONPatch != nil:
+            for _, op := range body.JSONPatch {
+                path, _ := op["path"].(string)
+                var toolsMeta map[string]any
+                switch {
+                case path == "":
+                    toolsMeta, _ = op["value"].(map[string]any)
+                case path == "/allowedTools":
+                    toolsMeta = map[string]any{"allowedTools": op["value"]}
+                case strings.HasPrefix(path, "/allowedTools/"):
+                    toolsMeta = map[string]any{"allowedTools": []any{op["value"]}}
+                }
+                if toolsMeta == nil || op["op"] == "remove" || op["op"] == "test" { continue }
+                if err := validateAllowedTools(cfg, toolsMeta); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+            }
+            patchBytes, _ := json.Marshal(body.JSONPatch)
+            in.MetadataPatch = patchBytes
+            in.PatchKind = repo.PatchKindJSONPatch
+        case body.Metadata != nil:
+            if err := validateAllowedTools(cfg, body.Metadata); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+            metaBytes, _ := json.Marshal(body.Metadata)
+            in.MetadataPatch = metaBytes
+            in.PatchKind = repo.PatchKindMerge
+        }
+
+        s, err := repo.UpdateSession(c.Request.Context(), dbh, id, in)
+        if err != nil {
+            var conflict *repo.ErrConflict
+            if errors.As(err, &conflict) {
+                c.JSON(http.StatusConflict, gin.H{"error": conflict.Error()})
+                return
+            }
+            c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+            return
+        }
         c.JSON(http.StatusOK, toSessionResponse(s))
     })
 
-    group.DELETE("/:sessionId", func(c *gin.Context) {
+    group.DELETE("/:sessionId", requireWrite, func(c *gin.Context) {
         id, err := uuid.Parse(c.Param("sessionId"))
         if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"}); return }
         if err := repo.DeleteSession(c.Request.Context(), dbh, id); err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"}); return }
@@ -101,6 +155,29 @@ func RegisterSessionRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB) {
     })
 }
 
+// validateAllowedTools rejects metadata.allowedTools entries that aren't in
+// cfg's configured tool catalog.
+func validateAllowedTools(cfg *config.Config, metadata map[string]any) error {
+    raw, ok := metadata["allowedTools"]
+    if !ok {
+        return nil
+    }
+    list, ok := raw.([]any)
+    if !ok {
+        return fmt.Errorf("metadata.allowedTools must be an array of strings")
+    }
+    for _, v := range list {
+        name, ok := v.(string)
+        if !ok {
+            return fmt.Errorf("metadata.allowedTools must be an array of strings")
+        }
+        if !cfg.IsKnownTool(name) {
+            return fmt.Errorf("unknown tool %q", name)
+        }
+    }
+    return nil
+}
+
 func toSessionResponse(s *repo.Session) gin.H {
     return gin.H{
         "id": s.ID.String(),
@@ -116,6 +193,8 @@ func toSessionResponse(s *repo.Session) gin.H {
         "isWorking": s.IsWorking,
         "currentJobId": s.CurrentJobID,
         "lastJobStatus": s.LastJobStatus,
+        "lastMessageSeq": s.LastMessageSeq,
+        "version": s.Version,
     }
 }
 
@@ -0,0 +1,327 @@
+package http
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "backend-go/internal/auth"
+    "backend-go/internal/config"
+    "backend-go/internal/db"
+    "backend-go/internal/repo"
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    redis "github.com/redis/go-redis/v9"
+)
+
+type createUploadBody struct {
+    FolderName string `json:"folderName" binding:"required"`
+    FileName   string `json:"fileName" binding:"required"`
+    TotalSize  int64  `json:"totalSize" binding:"required,min=1"`
+    SHA256     string `json:"sha256" binding:"required"`
+}
+
+type completeUploadBody struct {
+    SessionID *string `json:"sessionId"`
+}
+
+// RegisterUploadRoutes wires the resumable chunked upload API that lets
+// clients push large project files into cfg.ReposDir in pieces, tracking the
+// accepted size of each upload session in Redis so retries are idempotent.
+// rdb is the process's shared Redis client, reused here for auth rate
+// limiting and accepted-size tracking instead of dialing a new client.
+func RegisterUploadRoutes(r *gin.Engine, cfg *config.Config, dbh *db.DB, rdb redis.UniversalClient) error {
+    group := r.Group("/api/claude-code/uploads")
+    requireWrite := auth.Middleware(dbh, rdb, auth.ScopeUploadsWrite)
+
+    group.POST("/", requireWrite, func(c *gin.Context) {
+        var body createUploadBody
+        if err := c.ShouldBindJSON(&body); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if _, err := resolveUploadDest(cfg, body.FolderName, body.FileName); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        tempPath := filepath.Join(os.TempDir(), "pokecode-uploads", uuid.New().String())
+        if err := os.MkdirAll(filepath.Dir(tempPath), 0o755); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        u, err := repo.CreateUpload(c.Request.Context(), dbh, repo.CreateUploadInput{
+            FolderName: body.FolderName,
+            FileName:   body.FileName,
+            TotalSize:  body.TotalSize,
+            SHA256:     body.SHA256,
+            TempPath:   tempPath,
+        })
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := rdb.Set(c.Request.Context(), uploadSizeKey(u.ID), 0, 0).Err(); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        c.JSON(http.StatusCreated, gin.H{"uploadId": u.ID.String(), "acceptedSize": 0})
+    })
+
+    group.PATCH("/:uploadId", requireWrite, func(c *gin.Context) {
+        id, err := uuid.Parse(c.Param("uploadId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+            return
+        }
+        u, err := repo.GetUpload(c.Request.Context(), dbh, id)
+        if err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+            return
+        }
+
+        start, end, total, err := parseContentRange(c.GetHeader("Content-Range"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        accepted, err := rdb.Get(c.Request.Context(), uploadSizeKey(id)).Int64()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if start != accepted || total != u.TotalSize {
+            c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "range does not match accepted size", "acceptedSize": accepted})
+            return
+        }
+
+        f, err := os.OpenFile(u.TempPath, os.O_CREATE|os.O_WRONLY, 0o644)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        defer f.Close()
+        if _, err := f.Seek(start, io.SeekStart); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        n, err := io.Copy(f, c.Request.Body)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if start+n-1 != end {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "chunk size does not match Content-Range"})
+            return
+        }
+
+        newAccepted, err := casIncrAcceptedSize.Run(c.Request.Context(), rdb, []string{uploadSizeKey(id)}, start, n).Int64()
+        if err != nil {
+            if strings.Contains(err.Error(), staleAcceptedSizeMsg) {
+                accepted, _ := rdb.Get(c.Request.Context(), uploadSizeKey(id)).Int64()
+                c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "range does not match accepted size", "acceptedSize": accepted})
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"acceptedSize": newAccepted})
+    })
+
+    group.GET("/:uploadId", requireWrite, func(c *gin.Context) {
+        id, err := uuid.Parse(c.Param("uploadId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+            return
+        }
+        accepted, err := rdb.Get(c.Request.Context(), uploadSizeKey(id)).Int64()
+        if err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{"uploadId": id.String(), "acceptedSize": accepted})
+    })
+
+    group.POST("/:uploadId/complete", requireWrite, func(c *gin.Context) {
+        id, err := uuid.Parse(c.Param("uploadId"))
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+            return
+        }
+        var body completeUploadBody
+        if err := c.ShouldBindJSON(&body); err != nil && err != io.EOF {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+
+        u, err := repo.GetUpload(c.Request.Context(), dbh, id)
+        if err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+            return
+        }
+
+        accepted, err := rdb.Get(c.Request.Context(), uploadSizeKey(id)).Int64()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if accepted != u.TotalSize {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "upload is incomplete", "acceptedSize": accepted})
+            return
+        }
+
+        sum, err := sha256File(u.TempPath)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if !strings.EqualFold(sum, u.SHA256) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "sha256 mismatch"})
+            return
+        }
+
+        dest, err := resolveUploadDest(cfg, u.FolderName, u.FileName)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        if err := os.Rename(u.TempPath, dest); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        var sessionID *uuid.UUID
+        if body.SessionID != nil {
+            sid, err := uuid.Parse(*body.SessionID)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sessionId"})
+                return
+            }
+            sessionID = &sid
+        }
+        updated, err := repo.CompleteUpload(c.Request.Context(), dbh, id, sessionID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"uploadId": updated.ID.String(), "status": updated.Status, "path": dest})
+    })
+
+    return nil
+}
+
+func uploadSizeKey(id uuid.UUID) string { return "upload:" + id.String() + ":size" }
+
+// staleAcceptedSizeMsg is what casIncrAcceptedSize returns when the
+// accepted-size key no longer matches the chunk's expected start offset.
+const staleAcceptedSizeMsg = "stale accepted size"
+
+// casIncrAcceptedSize atomically checks that KEYS[1] (the upload's accepted
+// size) still equals ARGV[1] (the chunk's start offset) before incrementing
+// it by ARGV[2] (the chunk's byte count), so two concurrent/duplicate
+// requests for the same chunk can't both pass the check and double-count
+// the same bytes.
+var casIncrAcceptedSize = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current ~= tonumber(ARGV[1]) then
+    return redis.error_reply('` + staleAcceptedSizeMsg + `')
+end
+return redis.call('INCRBY', KEYS[1], ARGV[2])
+`)
+
+// resolveUploadDest validates folderName and fileName and returns the
+// absolute path they resolve to under cfg.ReposDir. Both must be plain path
+// segments: no path separators and no "..", and the resolved path must
+// still be inside cfg.ReposDir once joined, so a client can't use either
+// field to write outside the configured repos directory.
+func resolveUploadDest(cfg *config.Config, folderName, fileName string) (string, error) {
+    if err := validatePathSegment(folderName); err != nil {
+        return "", fmt.Errorf("folderName: %w", err)
+    }
+    if err := validatePathSegment(fileName); err != nil {
+        return "", fmt.Errorf("fileName: %w", err)
+    }
+
+    root, err := filepath.Abs(cfg.ReposDir)
+    if err != nil {
+        return "", fmt.Errorf("resolve ReposDir: %w", err)
+    }
+    dest := filepath.Join(root, folderName, fileName)
+    if dest != root && !strings.HasPrefix(dest, root+string(filepath.Separator)) {
+        return "", fmt.Errorf("resolved path escapes ReposDir")
+    }
+    return dest, nil
+}
+
+// validatePathSegment rejects anything that isn't a single plain path
+// component: no path separators, no "..", nothing empty.
+func validatePathSegment(s string) error {
+    if s == "" {
+        return fmt.Errorf("must not be empty")
+    }
+    if strings.ContainsAny(s, "/\\") {
+        return fmt.Errorf("must not contain a path separator")
+    }
+    if s == "." || s == ".." {
+        return fmt.Errorf("must not be %q", s)
+    }
+    return nil
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header.
+func parseContentRange(header string) (start, end, total int64, err error) {
+    const prefix = "bytes "
+    if !strings.HasPrefix(header, prefix) {
+        return 0, 0, 0, fmt.Errorf("missing or invalid Content-Range header")
+    }
+    rest := strings.TrimPrefix(header, prefix)
+    parts := strings.SplitN(rest, "/", 2)
+    if len(parts) != 2 {
+        return 0, 0, 0, fmt.Errorf("invalid Content-Range header")
+    }
+    rangeParts := strings.SplitN(parts[0], "-", 2)
+    if len(rangeParts) != 2 {
+        return 0, 0, 0, fmt.Errorf("invalid Content-Range header")
+    }
+    start, err = strconv.ParseInt(rangeParts[0], 10, 64)
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("invalid Content-Range start: %w", err)
+    }
+    end, err = strconv.ParseInt(rangeParts[1], 10, 64)
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("invalid Content-Range end: %w", err)
+    }
+    total, err = strconv.ParseInt(parts[1], 10, 64)
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("invalid Content-Range total: %w", err)
+    }
+    return start, end, total, nil
+}
+
+func sha256File(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -1,12 +1,30 @@
 package logz
 
 import (
+    "context"
     "os"
     "strings"
 
     "github.com/rs/zerolog"
 )
 
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying logger, retrievable later via
+// FromContext.
+func WithContext(ctx context.Context, logger zerolog.Logger) context.Context {
+    return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger attached to ctx by WithContext, or a
+// disabled no-op logger if none was attached.
+func FromContext(ctx context.Context) zerolog.Logger {
+    if logger, ok := ctx.Value(ctxKey{}).(zerolog.Logger); ok {
+        return logger
+    }
+    return zerolog.Nop()
+}
+
 func New(level string) zerolog.Logger {
     zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
     l := zerolog.InfoLevel
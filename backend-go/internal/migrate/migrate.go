@@ -1,12 +1,17 @@
+// Package migrate implements a small embeddable SQL migration runner:
+// migrations are paired "NNN_name.up.sql" / "NNN_name.down.sql" files read
+// from an fs.FS (so callers can ship them embedded in the binary via
+// //go:embed), applied under a Postgres advisory lock so two instances of
+// the binary can't race against the same database.
 package migrate
 
 import (
     "context"
     "crypto/sha1"
     "encoding/hex"
+    "fmt"
+    "hash/fnv"
     "io/fs"
-    "os"
-    "path/filepath"
     "sort"
     "strings"
     "time"
@@ -14,72 +19,359 @@ import (
     "backend-go/internal/db"
 )
 
-// Simple file-based migration runner applying all *.up.sql under dir in name order.
-func Up(ctx context.Context, d *db.DB, dir string) error {
-    // ensure schema_migrations table
-    _, err := d.Pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+// ChecksumMismatchError is returned by Up when a migration that was already
+// applied has since changed on disk. Re-running it silently could leave the
+// database in a state that doesn't match what schema_migrations claims was
+// run, so this fails loudly by default; pass Options.AllowDirty to opt into
+// the old re-apply behavior, or use Force to accept the new checksum.
+type ChecksumMismatchError struct {
+    Version string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+    return fmt.Sprintf("migrate: %s was already applied but its contents changed", e.Version)
+}
+
+// Options configures a Migrator.
+type Options struct {
+    // AllowDirty lets Up re-execute a migration whose checksum no longer
+    // matches what was recorded, instead of returning ChecksumMismatchError.
+    AllowDirty bool
+}
+
+// Migrator applies and inspects migrations read from src against d.
+type Migrator struct {
+    d    *db.DB
+    src  fs.FS
+    opts Options
+}
+
+// New constructs a Migrator. src is typically an embed.FS (optionally
+// narrowed with fs.Sub) or os.DirFS pointed at a migrations directory.
+func New(d *db.DB, src fs.FS, opts Options) *Migrator {
+    return &Migrator{d: d, src: src, opts: opts}
+}
+
+type migration struct {
+    Version  string
+    UpSQL    string
+    DownSQL  string
+    Checksum string
+}
+
+// advisoryLockKey derives a stable lock key from the runner's name so
+// concurrent processes agree on what they're locking without coordinating a
+// magic number by hand.
+func advisoryLockKey() int64 {
+    h := fnv.New64a()
+    _, _ = h.Write([]byte("pokecode_migrations"))
+    return int64(h.Sum64())
+}
+
+// withLock runs fn while holding a session-level Postgres advisory lock,
+// refusing to proceed if another process already holds it rather than
+// blocking indefinitely.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+    conn, err := m.d.Pool.Acquire(ctx)
+    if err != nil {
+        return fmt.Errorf("migrate: acquire connection: %w", err)
+    }
+    defer conn.Release()
+
+    key := advisoryLockKey()
+    var locked bool
+    if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+        return fmt.Errorf("migrate: acquire advisory lock: %w", err)
+    }
+    if !locked {
+        return fmt.Errorf("migrate: another process is already running migrations")
+    }
+    defer func() {
+        _, _ = conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, key)
+    }()
+
+    return fn(ctx)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+    _, err := m.d.Pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
         version TEXT PRIMARY KEY,
         checksum TEXT NOT NULL,
+        up_sql TEXT NOT NULL,
+        down_sql TEXT NOT NULL DEFAULT '',
         applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
     )`)
+    return err
+}
+
+// loadMigrations reads every NNN_name.up.sql / NNN_name.down.sql pair from
+// src and returns them sorted by version.
+func (m *Migrator) loadMigrations() ([]migration, error) {
+    entries, err := fs.ReadDir(m.src, ".")
     if err != nil {
-        return err
+        return nil, fmt.Errorf("migrate: read migrations dir: %w", err)
     }
 
-    // load applied versions
-    applied := map[string]string{}
-    rows, err := d.Pool.Query(ctx, `SELECT version, checksum FROM schema_migrations`)
+    byVersion := map[string]*migration{}
+    for _, e := range entries {
+        if e.IsDir() {
+            continue
+        }
+        name := e.Name()
+        var version, kind string
+        switch {
+        case strings.HasSuffix(name, ".up.sql"):
+            version, kind = strings.TrimSuffix(name, ".up.sql"), "up"
+        case strings.HasSuffix(name, ".down.sql"):
+            version, kind = strings.TrimSuffix(name, ".down.sql"), "down"
+        default:
+            continue
+        }
+
+        b, err := fs.ReadFile(m.src, name)
+        if err != nil {
+            return nil, fmt.Errorf("migrate: read %s: %w", name, err)
+        }
+        mig, ok := byVersion[version]
+        if !ok {
+            mig = &migration{Version: version}
+            byVersion[version] = mig
+        }
+        if kind == "up" {
+            mig.UpSQL = string(b)
+        } else {
+            mig.DownSQL = string(b)
+        }
+    }
+
+    out := make([]migration, 0, len(byVersion))
+    for _, mig := range byVersion {
+        if mig.UpSQL == "" {
+            return nil, fmt.Errorf("migrate: %s has a down.sql but no up.sql", mig.Version)
+        }
+        sum := sha1.Sum([]byte(mig.UpSQL))
+        mig.Checksum = hex.EncodeToString(sum[:])
+        out = append(out, *mig)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+    return out, nil
+}
+
+type appliedMigration struct {
+    Version   string
+    Checksum  string
+    DownSQL   string
+    AppliedAt time.Time
+}
+
+func (m *Migrator) applied(ctx context.Context) ([]appliedMigration, error) {
+    rows, err := m.d.Pool.Query(ctx, `SELECT version, checksum, down_sql, applied_at FROM schema_migrations ORDER BY version`)
     if err != nil {
-        return err
+        return nil, err
     }
+    defer rows.Close()
+
+    var out []appliedMigration
     for rows.Next() {
-        var v, c string
-        _ = rows.Scan(&v, &c)
-        applied[v] = c
+        var r appliedMigration
+        if err := rows.Scan(&r.Version, &r.Checksum, &r.DownSQL, &r.AppliedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, r)
     }
-    rows.Close()
+    return out, rows.Err()
+}
 
-    // read files
-    var files []string
-    _ = filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
-        if err != nil || de.IsDir() {
-            return nil
+// Up applies every pending migration in version order, in a transaction per
+// migration, under the migration advisory lock.
+func (m *Migrator) Up(ctx context.Context) error {
+    return m.withLock(ctx, func(ctx context.Context) error {
+        if err := m.ensureTable(ctx); err != nil {
+            return err
         }
-        if strings.HasSuffix(de.Name(), ".up.sql") {
-            files = append(files, path)
+        migs, err := m.loadMigrations()
+        if err != nil {
+            return err
+        }
+        applied, err := m.applied(ctx)
+        if err != nil {
+            return err
+        }
+        appliedByVersion := make(map[string]appliedMigration, len(applied))
+        for _, r := range applied {
+            appliedByVersion[r.Version] = r
+        }
+
+        for _, mig := range migs {
+            existing, ok := appliedByVersion[mig.Version]
+            if ok {
+                if existing.Checksum == mig.Checksum {
+                    continue
+                }
+                if !m.opts.AllowDirty {
+                    return &ChecksumMismatchError{Version: mig.Version}
+                }
+                // AllowDirty: drop the stale record so applyOne re-runs the
+                // changed SQL and records its new checksum.
+                if _, err := m.d.Pool.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+                    return fmt.Errorf("migrate: clear dirty record %s: %w", mig.Version, err)
+                }
+            }
+            if err := m.applyOne(ctx, mig); err != nil {
+                return err
+            }
         }
         return nil
     })
-    sort.Strings(files)
+}
+
+func (m *Migrator) applyOne(ctx context.Context, mig migration) error {
+    tx, err := m.d.Pool.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, mig.UpSQL); err != nil {
+        _ = tx.Rollback(ctx)
+        return fmt.Errorf("migrate: apply %s: %w", mig.Version, err)
+    }
+    if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, checksum, up_sql, down_sql) VALUES ($1, $2, $3, $4)`,
+        mig.Version, mig.Checksum, mig.UpSQL, mig.DownSQL); err != nil {
+        _ = tx.Rollback(ctx)
+        return fmt.Errorf("migrate: record %s: %w", mig.Version, err)
+    }
+    return tx.Commit(ctx)
+}
 
-    for _, f := range files {
-        b, err := os.ReadFile(f)
+// Down rolls back up to steps of the most recently applied migrations, most
+// recent first, failing if any of them has no recorded down SQL.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+    return m.withLock(ctx, func(ctx context.Context) error {
+        if err := m.ensureTable(ctx); err != nil {
+            return err
+        }
+        applied, err := m.applied(ctx)
         if err != nil {
             return err
         }
-        version := filepath.Base(f)
-        sum := sha1.Sum(b)
-        hexsum := hex.EncodeToString(sum[:])
-        if old, ok := applied[version]; ok {
-            if old == hexsum {
-                continue // already applied
+        sort.Slice(applied, func(i, j int) bool { return applied[i].Version > applied[j].Version })
+        if steps > len(applied) {
+            steps = len(applied)
+        }
+
+        for _, r := range applied[:steps] {
+            if strings.TrimSpace(r.DownSQL) == "" {
+                return fmt.Errorf("migrate: %s has no down migration", r.Version)
+            }
+            if err := m.rollbackOne(ctx, r); err != nil {
+                return err
             }
-            // checksum changed: re-apply by transaction (naive approach)
         }
-        // execute as single batch
-        tx, err := d.Pool.Begin(ctx)
-        if err != nil { return err }
-        if _, err := tx.Exec(ctx, string(b)); err != nil {
-            _ = tx.Rollback(ctx)
+        return nil
+    })
+}
+
+func (m *Migrator) rollbackOne(ctx context.Context, r appliedMigration) error {
+    tx, err := m.d.Pool.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, r.DownSQL); err != nil {
+        _ = tx.Rollback(ctx)
+        return fmt.Errorf("migrate: rollback %s: %w", r.Version, err)
+    }
+    if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, r.Version); err != nil {
+        _ = tx.Rollback(ctx)
+        return fmt.Errorf("migrate: unrecord %s: %w", r.Version, err)
+    }
+    return tx.Commit(ctx)
+}
+
+// Redo rolls back and reapplies the single most recent migration.
+func (m *Migrator) Redo(ctx context.Context) error {
+    if err := m.Down(ctx, 1); err != nil {
+        return err
+    }
+    return m.Up(ctx)
+}
+
+// StatusEntry describes one migration known to the source, whether or not
+// it's been applied.
+type StatusEntry struct {
+    Version   string
+    Applied   bool
+    AppliedAt time.Time
+}
+
+// Status reports every migration found in the source alongside whether it
+// has been applied, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]StatusEntry, error) {
+    if err := m.ensureTable(ctx); err != nil {
+        return nil, err
+    }
+    migs, err := m.loadMigrations()
+    if err != nil {
+        return nil, err
+    }
+    applied, err := m.applied(ctx)
+    if err != nil {
+        return nil, err
+    }
+    appliedByVersion := make(map[string]appliedMigration, len(applied))
+    for _, r := range applied {
+        appliedByVersion[r.Version] = r
+    }
+
+    out := make([]StatusEntry, 0, len(migs))
+    for _, mig := range migs {
+        entry := StatusEntry{Version: mig.Version}
+        if r, ok := appliedByVersion[mig.Version]; ok {
+            entry.Applied = true
+            entry.AppliedAt = r.AppliedAt
+        }
+        out = append(out, entry)
+    }
+    return out, nil
+}
+
+// Version returns the most recently applied migration's version, or "" if
+// none have been applied yet.
+func (m *Migrator) Version(ctx context.Context) (string, error) {
+    if err := m.ensureTable(ctx); err != nil {
+        return "", err
+    }
+    applied, err := m.applied(ctx)
+    if err != nil {
+        return "", err
+    }
+    if len(applied) == 0 {
+        return "", nil
+    }
+    sort.Slice(applied, func(i, j int) bool { return applied[i].Version > applied[j].Version })
+    return applied[0].Version, nil
+}
+
+// Force marks version as applied using its current on-disk checksum without
+// running its SQL, for recovering from a ChecksumMismatchError once an
+// operator has confirmed the change by hand.
+func (m *Migrator) Force(ctx context.Context, version string) error {
+    return m.withLock(ctx, func(ctx context.Context) error {
+        if err := m.ensureTable(ctx); err != nil {
             return err
         }
-        if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations(version, checksum, applied_at) VALUES($1,$2,$3)
-            ON CONFLICT(version) DO UPDATE SET checksum=EXCLUDED.checksum, applied_at=EXCLUDED.applied_at`, version, hexsum, time.Now()); err != nil {
-            _ = tx.Rollback(ctx)
+        migs, err := m.loadMigrations()
+        if err != nil {
             return err
         }
-        if err := tx.Commit(ctx); err != nil { return err }
-    }
-    return nil
+        for _, mig := range migs {
+            if mig.Version != version {
+                continue
+            }
+            _, err := m.d.Pool.Exec(ctx, `INSERT INTO schema_migrations (version, checksum, up_sql, down_sql)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (version) DO UPDATE SET checksum = EXCLUDED.checksum, up_sql = EXCLUDED.up_sql, down_sql = EXCLUDED.down_sql`,
+                mig.Version, mig.Checksum, mig.UpSQL, mig.DownSQL)
+            return err
+        }
+        return fmt.Errorf("migrate: unknown version %q", version)
+    })
 }
-
@@ -4,9 +4,8 @@ import (
     "context"
     "encoding/json"
 
-    "backend-go/internal/config"
     "github.com/hibiken/asynq"
-    "github.com/rs/zerolog"
+    redis "github.com/redis/go-redis/v9"
 )
 
 const (
@@ -17,13 +16,12 @@ type Client struct {
     asynq *asynq.Client
 }
 
-func NewClient(cfg *config.Config) (*Client, error) {
-    opt, err := asynq.ParseRedisURI(cfg.RedisURL)
-    if err != nil {
-        return nil, err
-    }
-    c := asynq.NewClient(opt)
-    return &Client{asynq: c}, nil
+// NewClient builds a queue Client on top of rdb, the process's shared Redis
+// client (see redisx.NewUniversalClient), instead of letting asynq parse and
+// dial RedisURL itself — so a Sentinel or Cluster topology configured via
+// cfg reaches the job queue too, not just a plain single-node connection.
+func NewClient(rdb redis.UniversalClient) *Client {
+    return &Client{asynq: asynq.NewClientFromRedisClient(rdb)}
 }
 
 func (c *Client) Close() error { return c.asynq.Close() }
@@ -47,15 +45,13 @@ func (c *Client) EnqueuePrompt(ctx context.Context, p PromptTask) error {
     return err
 }
 
-// Server
-func NewServer(cfg *config.Config, logger zerolog.Logger) (*asynq.Server, *asynq.ServeMux, error) {
-    opt, err := asynq.ParseRedisURI(cfg.RedisURL)
-    if err != nil {
-        return nil, nil, err
-    }
-    srv := asynq.NewServer(opt, asynq.Config{
+// NewServer builds an asynq server on top of the same shared rdb as NewClient,
+// for the same reason: the worker should honor whatever Redis topology cfg
+// describes, not just a plain RedisURL.
+func NewServer(rdb redis.UniversalClient) (*asynq.Server, *asynq.ServeMux) {
+    srv := asynq.NewServerFromRedisClient(rdb, asynq.Config{
         Concurrency: 5,
     })
     mux := asynq.NewServeMux()
-    return srv, mux, nil
+    return srv, mux
 }
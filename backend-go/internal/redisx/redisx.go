@@ -0,0 +1,83 @@
+// Package redisx centralizes how this service builds its Redis client so
+// every call site (health checks, the worker, the HTTP routes, auth rate
+// limiting) agrees on URL parsing, TLS, and which topology (single node,
+// Sentinel, or Cluster) is in play, instead of each one re-parsing
+// cfg.RedisURL by hand.
+package redisx
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "os"
+    "strings"
+
+    "backend-go/internal/config"
+    redis "github.com/redis/go-redis/v9"
+)
+
+// NewUniversalClient builds a redis.UniversalClient from cfg: a Cluster
+// client if RedisClusterAddrs is set, a Sentinel-backed failover client if
+// RedisSentinelMaster is set, otherwise a single-node client parsed from
+// RedisURL. A rediss:// URL, or either of the REDIS_TLS_* settings, enables
+// TLS on any of the three.
+func NewUniversalClient(cfg *config.Config) (redis.UniversalClient, error) {
+    switch {
+    case len(cfg.RedisClusterAddrs) > 0:
+        tlsConfig, err := tlsConfigFor(cfg)
+        if err != nil {
+            return nil, err
+        }
+        return redis.NewClusterClient(&redis.ClusterOptions{
+            Addrs:     cfg.RedisClusterAddrs,
+            TLSConfig: tlsConfig,
+        }), nil
+
+    case cfg.RedisSentinelMaster != "":
+        tlsConfig, err := tlsConfigFor(cfg)
+        if err != nil {
+            return nil, err
+        }
+        return redis.NewFailoverClient(&redis.FailoverOptions{
+            MasterName:    cfg.RedisSentinelMaster,
+            SentinelAddrs: cfg.RedisSentinelAddrs,
+            TLSConfig:     tlsConfig,
+        }), nil
+
+    default:
+        opt, err := redis.ParseURL(cfg.RedisURL)
+        if err != nil {
+            return nil, fmt.Errorf("redisx: parse REDIS_URL: %w", err)
+        }
+        tlsConfig, err := tlsConfigFor(cfg)
+        if err != nil {
+            return nil, err
+        }
+        if tlsConfig != nil {
+            opt.TLSConfig = tlsConfig
+        }
+        return redis.NewClient(opt), nil
+    }
+}
+
+// tlsConfigFor returns nil when TLS wasn't requested, so callers can leave
+// *redis.Options.TLSConfig unset and get the library's plaintext default.
+func tlsConfigFor(cfg *config.Config) (*tls.Config, error) {
+    if !strings.HasPrefix(cfg.RedisURL, "rediss://") && cfg.RedisTLSCAFile == "" && !cfg.RedisTLSInsecureSkipVerify {
+        return nil, nil
+    }
+
+    conf := &tls.Config{InsecureSkipVerify: cfg.RedisTLSInsecureSkipVerify}
+    if cfg.RedisTLSCAFile != "" {
+        pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+        if err != nil {
+            return nil, fmt.Errorf("redisx: read REDIS_TLS_CA_FILE: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("redisx: no certificates found in REDIS_TLS_CA_FILE")
+        }
+        conf.RootCAs = pool
+    }
+    return conf, nil
+}
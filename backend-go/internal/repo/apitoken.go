@@ -0,0 +1,55 @@
+package repo
+
+import (
+    "context"
+    "time"
+
+    "backend-go/internal/db"
+    "github.com/google/uuid"
+)
+
+type APIToken struct {
+    ID                 uuid.UUID
+    HashedToken        string
+    Name               string
+    Scopes             []string
+    RateLimitPerMinute int
+    CreatedAt          time.Time
+    LastUsedAt         *time.Time
+    RevokedAt          *time.Time
+}
+
+type CreateAPITokenInput struct {
+    HashedToken        string
+    Name               string
+    Scopes             []string
+    RateLimitPerMinute int
+}
+
+func CreateAPIToken(ctx context.Context, d *db.DB, in CreateAPITokenInput) (*APIToken, error) {
+    id := uuid.New()
+    row := d.Pool.QueryRow(ctx, `INSERT INTO api_tokens (id, hashed_token, name, scopes, rate_limit_per_minute)
+        VALUES ($1,$2,$3,$4,$5)
+        RETURNING id, hashed_token, name, scopes, rate_limit_per_minute, created_at, last_used_at, revoked_at`,
+        id, in.HashedToken, in.Name, in.Scopes, in.RateLimitPerMinute)
+    var t APIToken
+    if err := row.Scan(&t.ID, &t.HashedToken, &t.Name, &t.Scopes, &t.RateLimitPerMinute, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
+
+func GetAPIToken(ctx context.Context, d *db.DB, id uuid.UUID) (*APIToken, error) {
+    row := d.Pool.QueryRow(ctx, `SELECT id, hashed_token, name, scopes, rate_limit_per_minute, created_at, last_used_at, revoked_at
+        FROM api_tokens WHERE id=$1`, id)
+    var t APIToken
+    if err := row.Scan(&t.ID, &t.HashedToken, &t.Name, &t.Scopes, &t.RateLimitPerMinute, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
+
+func TouchAPIToken(ctx context.Context, d *db.DB, id uuid.UUID) error {
+    _, err := d.Pool.Exec(ctx, `UPDATE api_tokens SET last_used_at=NOW() WHERE id=$1`, id)
+    return err
+}
@@ -0,0 +1,241 @@
+package repo
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// jsonPatchOp is one operation in an RFC 6902 JSON Patch document.
+type jsonPatchOp struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path"`
+    Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch document to doc. Supports
+// add, remove, replace, and test; move and copy are not implemented since
+// nothing in this codebase needs them yet.
+func applyJSONPatch(doc []byte, patch []byte) ([]byte, error) {
+    var ops []jsonPatchOp
+    if err := json.Unmarshal(patch, &ops); err != nil {
+        return nil, fmt.Errorf("invalid json patch: %w", err)
+    }
+
+    var root any = map[string]any{}
+    if len(doc) > 0 {
+        if err := json.Unmarshal(doc, &root); err != nil {
+            return nil, fmt.Errorf("invalid target document: %w", err)
+        }
+    }
+
+    for _, op := range ops {
+        tokens, err := pointerTokens(op.Path)
+        if err != nil {
+            return nil, err
+        }
+
+        switch op.Op {
+        case "add", "replace":
+            var val any
+            if err := json.Unmarshal(op.Value, &val); err != nil {
+                return nil, fmt.Errorf("json patch %s %s: %w", op.Op, op.Path, err)
+            }
+            if len(tokens) == 0 {
+                root = val
+                continue
+            }
+            if root, err = setAtPointer(root, tokens, val, op.Op == "add"); err != nil {
+                return nil, err
+            }
+        case "remove":
+            if root, err = removeAtPointer(root, tokens); err != nil {
+                return nil, err
+            }
+        case "test":
+            var want any
+            if err := json.Unmarshal(op.Value, &want); err != nil {
+                return nil, fmt.Errorf("json patch test %s: %w", op.Path, err)
+            }
+            got, err := getAtPointer(root, tokens)
+            if err != nil {
+                return nil, err
+            }
+            if !reflect.DeepEqual(got, want) {
+                return nil, fmt.Errorf("json patch: test failed at %q", op.Path)
+            }
+        default:
+            return nil, fmt.Errorf("json patch: unsupported op %q", op.Op)
+        }
+    }
+
+    return json.Marshal(root)
+}
+
+// pointerTokens splits an RFC 6901 JSON Pointer into its unescaped segments.
+func pointerTokens(ptr string) ([]string, error) {
+    if ptr == "" {
+        return nil, nil
+    }
+    if ptr[0] != '/' {
+        return nil, fmt.Errorf("json patch: path %q must start with /", ptr)
+    }
+    raw := strings.Split(ptr[1:], "/")
+    tokens := make([]string, len(raw))
+    for i, t := range raw {
+        t = strings.ReplaceAll(t, "~1", "/")
+        t = strings.ReplaceAll(t, "~0", "~")
+        tokens[i] = t
+    }
+    return tokens, nil
+}
+
+func getAtPointer(node any, tokens []string) (any, error) {
+    cur := node
+    for _, tok := range tokens {
+        switch v := cur.(type) {
+        case map[string]any:
+            val, ok := v[tok]
+            if !ok {
+                return nil, fmt.Errorf("json patch: path segment %q not found", tok)
+            }
+            cur = val
+        case []any:
+            idx, err := arrayIndex(tok, len(v))
+            if err != nil {
+                return nil, err
+            }
+            cur = v[idx]
+        default:
+            return nil, fmt.Errorf("json patch: cannot descend into non-container at %q", tok)
+        }
+    }
+    return cur, nil
+}
+
+// setAtPointer rebuilds node with val stored at tokens, returning the
+// (possibly new, if an array had to grow) root value.
+func setAtPointer(node any, tokens []string, val any, insert bool) (any, error) {
+    tok := tokens[0]
+    if len(tokens) == 1 {
+        switch v := node.(type) {
+        case map[string]any:
+            if _, ok := v[tok]; !ok && !insert {
+                return nil, fmt.Errorf("json patch: path segment %q not found", tok)
+            }
+            v[tok] = val
+            return v, nil
+        case []any:
+            if insert {
+                idx := len(v)
+                if tok != "-" {
+                    var err error
+                    if idx, err = arrayIndex(tok, len(v)+1); err != nil {
+                        return nil, err
+                    }
+                }
+                v = append(v, nil)
+                copy(v[idx+1:], v[idx:])
+                v[idx] = val
+                return v, nil
+            }
+            idx, err := arrayIndex(tok, len(v))
+            if err != nil {
+                return nil, err
+            }
+            v[idx] = val
+            return v, nil
+        default:
+            return nil, fmt.Errorf("json patch: cannot set into non-container at %q", tok)
+        }
+    }
+
+    switch v := node.(type) {
+    case map[string]any:
+        child, ok := v[tok]
+        if !ok {
+            return nil, fmt.Errorf("json patch: path segment %q not found", tok)
+        }
+        newChild, err := setAtPointer(child, tokens[1:], val, insert)
+        if err != nil {
+            return nil, err
+        }
+        v[tok] = newChild
+        return v, nil
+    case []any:
+        idx, err := arrayIndex(tok, len(v))
+        if err != nil {
+            return nil, err
+        }
+        newChild, err := setAtPointer(v[idx], tokens[1:], val, insert)
+        if err != nil {
+            return nil, err
+        }
+        v[idx] = newChild
+        return v, nil
+    default:
+        return nil, fmt.Errorf("json patch: cannot descend into non-container at %q", tok)
+    }
+}
+
+func removeAtPointer(node any, tokens []string) (any, error) {
+    if len(tokens) == 0 {
+        return nil, fmt.Errorf("json patch: cannot remove the document root")
+    }
+    tok := tokens[0]
+    if len(tokens) == 1 {
+        switch v := node.(type) {
+        case map[string]any:
+            if _, ok := v[tok]; !ok {
+                return nil, fmt.Errorf("json patch: path segment %q not found", tok)
+            }
+            delete(v, tok)
+            return v, nil
+        case []any:
+            idx, err := arrayIndex(tok, len(v))
+            if err != nil {
+                return nil, err
+            }
+            return append(v[:idx], v[idx+1:]...), nil
+        default:
+            return nil, fmt.Errorf("json patch: cannot remove from non-container at %q", tok)
+        }
+    }
+
+    switch v := node.(type) {
+    case map[string]any:
+        child, ok := v[tok]
+        if !ok {
+            return nil, fmt.Errorf("json patch: path segment %q not found", tok)
+        }
+        newChild, err := removeAtPointer(child, tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        v[tok] = newChild
+        return v, nil
+    case []any:
+        idx, err := arrayIndex(tok, len(v))
+        if err != nil {
+            return nil, err
+        }
+        newChild, err := removeAtPointer(v[idx], tokens[1:])
+        if err != nil {
+            return nil, err
+        }
+        v[idx] = newChild
+        return v, nil
+    default:
+        return nil, fmt.Errorf("json patch: cannot descend into non-container at %q", tok)
+    }
+}
+
+func arrayIndex(tok string, length int) (int, error) {
+    idx, err := strconv.Atoi(tok)
+    if err != nil || idx < 0 || idx >= length {
+        return 0, fmt.Errorf("json patch: array index %q out of range", tok)
+    }
+    return idx, nil
+}
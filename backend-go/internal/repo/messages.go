@@ -0,0 +1,54 @@
+package repo
+
+import (
+    "context"
+    "encoding/json"
+    "time"
+
+    "backend-go/internal/claude/events"
+    "backend-go/internal/db"
+    "github.com/google/uuid"
+)
+
+type Message struct {
+    Seq       int64
+    Type      string
+    Payload   []byte // raw JSON event envelope
+    CreatedAt time.Time
+}
+
+// AppendMessage persists an event for a prompt, assigning it the next
+// sequence number for that session/prompt pair.
+func AppendMessage(ctx context.Context, d *db.DB, sessionID uuid.UUID, promptID string, ev events.Event) (int64, error) {
+    payload, err := json.Marshal(ev)
+    if err != nil {
+        return 0, err
+    }
+    var seq int64
+    row := d.Pool.QueryRow(ctx, `INSERT INTO prompt_messages (session_id, prompt_id, seq, type, payload)
+        VALUES ($1, $2, COALESCE((SELECT MAX(seq) FROM prompt_messages WHERE session_id=$1 AND prompt_id=$2), 0) + 1, $3, $4)
+        RETURNING seq`, sessionID, promptID, string(ev.Type), payload)
+    if err := row.Scan(&seq); err != nil {
+        return 0, err
+    }
+    return seq, nil
+}
+
+// ListMessages returns the ordered event history for a prompt.
+func ListMessages(ctx context.Context, d *db.DB, sessionID uuid.UUID, promptID string) ([]Message, error) {
+    rows, err := d.Pool.Query(ctx, `SELECT seq, type, payload, created_at FROM prompt_messages
+        WHERE session_id=$1 AND prompt_id=$2 ORDER BY seq ASC`, sessionID, promptID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var out []Message
+    for rows.Next() {
+        var m Message
+        if err := rows.Scan(&m.Seq, &m.Type, &m.Payload, &m.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, m)
+    }
+    return out, nil
+}
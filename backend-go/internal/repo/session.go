@@ -3,6 +3,7 @@ package repo
 import (
     "context"
     "errors"
+    "fmt"
     "time"
 
     "backend-go/internal/claude"
@@ -24,8 +25,37 @@ type Session struct {
     IsWorking          bool
     CurrentJobID       *string
     LastJobStatus      *string
+    LastMessageSeq     int64
+    Version            int64
 }
 
+// ErrConflict is returned by UpdateSession when ExpectedVersion was set and
+// didn't match the session's current version, i.e. someone else updated it
+// first.
+type ErrConflict struct {
+    ID              uuid.UUID
+    ExpectedVersion int64
+    ActualVersion   int64
+}
+
+func (e *ErrConflict) Error() string {
+    return fmt.Sprintf("repo: session %s version conflict: expected %d, found %d", e.ID, e.ExpectedVersion, e.ActualVersion)
+}
+
+// PatchKind selects how UpdateSessionInput.MetadataPatch is applied to the
+// session's existing metadata.
+type PatchKind string
+
+const (
+    // PatchKindMerge applies MetadataPatch as an RFC 7396 JSON Merge Patch:
+    // a null value deletes the corresponding key, everything else is merged
+    // in (recursively, for nested objects). This is the default.
+    PatchKindMerge PatchKind = "merge"
+    // PatchKindJSONPatch applies MetadataPatch as an RFC 6902 JSON Patch
+    // operation array.
+    PatchKindJSONPatch PatchKind = "json-patch"
+)
+
 type CreateSessionInput struct {
     ProjectPath string
     Context     *string
@@ -37,20 +67,21 @@ func CreateSession(ctx context.Context, d *db.DB, in CreateSessionInput) (*Sessi
     dir := claude.ClaudeDirectoryPath(in.ProjectPath, id.String())
     row := d.Pool.QueryRow(ctx, `INSERT INTO claude_code_sessions (id, project_path, context, status, claude_directory_path, metadata)
         VALUES ($1,$2,$3,'active',$4,$5)
-        RETURNING id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status`,
+        RETURNING id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status, version`,
         id, in.ProjectPath, in.Context, dir, in.Metadata)
     var s Session
-    if err := row.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus); err != nil {
+    if err := row.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus, &s.Version); err != nil {
         return nil, err
     }
     return &s, nil
 }
 
 func GetSession(ctx context.Context, d *db.DB, id uuid.UUID) (*Session, error) {
-    row := d.Pool.QueryRow(ctx, `SELECT id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status
+    row := d.Pool.QueryRow(ctx, `SELECT id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status, version,
+        (SELECT COALESCE(MAX(seq), 0) FROM prompt_messages WHERE session_id = claude_code_sessions.id)
         FROM claude_code_sessions WHERE id=$1`, id)
     var s Session
-    if err := row.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus); err != nil {
+    if err := row.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus, &s.Version, &s.LastMessageSeq); err != nil {
         return nil, err
     }
     // update last accessed
@@ -72,31 +103,94 @@ func ListSessions(ctx context.Context, d *db.DB, opt ListOptions) ([]Session, in
     if err := d.Pool.QueryRow(ctx, `SELECT count(*) FROM claude_code_sessions `+where, args...).Scan(&count); err != nil { return nil, 0, err }
     // list
     if opt.Limit == 0 { opt.Limit = 20 }
-    rows, err := d.Pool.Query(ctx, `SELECT id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status
+    rows, err := d.Pool.Query(ctx, `SELECT id, project_path, context, status, claude_directory_path, claude_code_session_id, metadata, created_at, updated_at, last_accessed_at, is_working, current_job_id, last_job_status, version,
+        (SELECT COALESCE(MAX(seq), 0) FROM prompt_messages WHERE session_id = claude_code_sessions.id)
         FROM claude_code_sessions `+where+` ORDER BY last_accessed_at DESC LIMIT $2 OFFSET $3`, append(args, opt.Limit, opt.Offset)...)
     if err != nil { return nil, 0, err }
     defer rows.Close()
     var out []Session
     for rows.Next() {
         var s Session
-        if err := rows.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus); err != nil { return nil, 0, err }
+        if err := rows.Scan(&s.ID, &s.ProjectPath, &s.Context, &s.Status, &s.ClaudeDirectoryPath, &s.ClaudeCodeSessionID, &s.Metadata, &s.CreatedAt, &s.UpdatedAt, &s.LastAccessedAt, &s.IsWorking, &s.CurrentJobID, &s.LastJobStatus, &s.Version, &s.LastMessageSeq); err != nil { return nil, 0, err }
         out = append(out, s)
     }
     return out, count, nil
 }
 
-type UpdateSessionInput struct { Context *string; Status *string; Metadata []byte }
+type UpdateSessionInput struct {
+    Context *string
+    Status  *string
+
+    // MetadataPatch, if set, is applied to the session's existing metadata
+    // according to PatchKind (RFC 7396 merge patch by default, or an RFC
+    // 6902 JSON Patch operation array). Leave nil to leave metadata alone.
+    MetadataPatch []byte
+    PatchKind     PatchKind
 
+    // ExpectedVersion, if set, must match the session's current version or
+    // the update is rejected with *ErrConflict and nothing is written.
+    ExpectedVersion *int64
+}
+
+// UpdateSession applies in to session id inside a transaction: it locks the
+// row, optionally checks ExpectedVersion for optimistic concurrency,
+// applies any MetadataPatch, and bumps version on success.
 func UpdateSession(ctx context.Context, d *db.DB, id uuid.UUID, in UpdateSessionInput) (*Session, error) {
-    // fetch current to merge metadata
-    cur, err := GetSession(ctx, d, id)
-    if err != nil { return nil, err }
-    // naive updates
-    _, err = d.Pool.Exec(ctx, `UPDATE claude_code_sessions SET context=COALESCE($2,context), status=COALESCE($3,status), metadata=COALESCE($4,metadata), updated_at=NOW() WHERE id=$1`, id, in.Context, in.Status, coalesceJSON(in.Metadata, cur.Metadata))
-    if err != nil { return nil, err }
+    tx, err := d.Pool.Begin(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback(ctx) // no-op once the transaction has been committed
+
+    var curMetadata []byte
+    var curVersion int64
+    if err := tx.QueryRow(ctx, `SELECT metadata, version FROM claude_code_sessions WHERE id=$1 FOR UPDATE`, id).Scan(&curMetadata, &curVersion); err != nil {
+        return nil, err
+    }
+
+    if in.ExpectedVersion != nil && *in.ExpectedVersion != curVersion {
+        return nil, &ErrConflict{ID: id, ExpectedVersion: *in.ExpectedVersion, ActualVersion: curVersion}
+    }
+
+    newMetadata := curMetadata
+    if len(in.MetadataPatch) > 0 {
+        switch in.PatchKind {
+        case PatchKindJSONPatch:
+            newMetadata, err = applyJSONPatch(curMetadata, in.MetadataPatch)
+            if err != nil {
+                return nil, fmt.Errorf("repo: apply json patch: %w", err)
+            }
+        default:
+            // RFC 7396 merge patch: the merge/null-delete semantics live in
+            // the jsonb_merge_patch SQL function so there's one definition
+            // of "merge" shared by every caller, not a Go reimplementation
+            // that could drift from it.
+            if err := tx.QueryRow(ctx, `SELECT jsonb_merge_patch(COALESCE($1::jsonb, '{}'::jsonb), $2::jsonb)`, curMetadata, in.MetadataPatch).Scan(&newMetadata); err != nil {
+                return nil, fmt.Errorf("repo: apply merge patch: %w", err)
+            }
+        }
+    }
+
+    if _, err := tx.Exec(ctx, `UPDATE claude_code_sessions
+        SET context=COALESCE($2,context), status=COALESCE($3,status), metadata=$4, version=version+1, updated_at=NOW()
+        WHERE id=$1`, id, in.Context, in.Status, newMetadata); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(ctx); err != nil {
+        return nil, err
+    }
     return GetSession(ctx, d, id)
 }
 
+// UpdateJobState records whether a session currently has a prompt in flight,
+// set by the worker around a job's lifetime rather than by client PATCHes.
+func UpdateJobState(ctx context.Context, d *db.DB, id uuid.UUID, isWorking bool, jobID *string, lastJobStatus *string) error {
+    _, err := d.Pool.Exec(ctx, `UPDATE claude_code_sessions SET is_working=$2, current_job_id=$3, last_job_status=COALESCE($4, last_job_status), updated_at=NOW() WHERE id=$1`,
+        id, isWorking, jobID, lastJobStatus)
+    return err
+}
+
 func DeleteSession(ctx context.Context, d *db.DB, id uuid.UUID) error {
     ct, err := d.Pool.Exec(ctx, `DELETE FROM claude_code_sessions WHERE id=$1`, id)
     if err != nil { return err }
@@ -104,8 +198,3 @@ func DeleteSession(ctx context.Context, d *db.DB, id uuid.UUID) error {
     return nil
 }
 
-func coalesceJSON(new []byte, old []byte) []byte {
-    if len(new) > 0 { return new }
-    return old
-}
-
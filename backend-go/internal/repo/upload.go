@@ -0,0 +1,62 @@
+package repo
+
+import (
+    "context"
+    "time"
+
+    "backend-go/internal/db"
+    "github.com/google/uuid"
+)
+
+type Upload struct {
+    ID         uuid.UUID
+    FolderName string
+    FileName   string
+    TotalSize  int64
+    SHA256     string
+    TempPath   string
+    Status     string
+    SessionID  *uuid.UUID
+    CreatedAt  time.Time
+    UpdatedAt  time.Time
+}
+
+type CreateUploadInput struct {
+    FolderName string
+    FileName   string
+    TotalSize  int64
+    SHA256     string
+    TempPath   string
+}
+
+func CreateUpload(ctx context.Context, d *db.DB, in CreateUploadInput) (*Upload, error) {
+    id := uuid.New()
+    row := d.Pool.QueryRow(ctx, `INSERT INTO uploads (id, folder_name, file_name, total_size, sha256, temp_path, status)
+        VALUES ($1,$2,$3,$4,$5,$6,'pending')
+        RETURNING id, folder_name, file_name, total_size, sha256, temp_path, status, session_id, created_at, updated_at`,
+        id, in.FolderName, in.FileName, in.TotalSize, in.SHA256, in.TempPath)
+    var u Upload
+    if err := row.Scan(&u.ID, &u.FolderName, &u.FileName, &u.TotalSize, &u.SHA256, &u.TempPath, &u.Status, &u.SessionID, &u.CreatedAt, &u.UpdatedAt); err != nil {
+        return nil, err
+    }
+    return &u, nil
+}
+
+func GetUpload(ctx context.Context, d *db.DB, id uuid.UUID) (*Upload, error) {
+    row := d.Pool.QueryRow(ctx, `SELECT id, folder_name, file_name, total_size, sha256, temp_path, status, session_id, created_at, updated_at
+        FROM uploads WHERE id=$1`, id)
+    var u Upload
+    if err := row.Scan(&u.ID, &u.FolderName, &u.FileName, &u.TotalSize, &u.SHA256, &u.TempPath, &u.Status, &u.SessionID, &u.CreatedAt, &u.UpdatedAt); err != nil {
+        return nil, err
+    }
+    return &u, nil
+}
+
+// CompleteUpload marks an upload as complete and optionally links it to a session.
+func CompleteUpload(ctx context.Context, d *db.DB, id uuid.UUID, sessionID *uuid.UUID) (*Upload, error) {
+    _, err := d.Pool.Exec(ctx, `UPDATE uploads SET status='complete', session_id=COALESCE($2, session_id), updated_at=NOW() WHERE id=$1`, id, sessionID)
+    if err != nil {
+        return nil, err
+    }
+    return GetUpload(ctx, d, id)
+}
@@ -3,72 +3,144 @@ package worker
 import (
     "context"
     "encoding/json"
-    "time"
+    "sync"
 
     "backend-go/internal/claude"
+    "backend-go/internal/claude/events"
     "backend-go/internal/config"
+    "backend-go/internal/db"
     "backend-go/internal/queue"
+    "backend-go/internal/repo"
+    "github.com/google/uuid"
     "github.com/hibiken/asynq"
     redis "github.com/redis/go-redis/v9"
     "github.com/rs/zerolog"
 )
 
-func RegisterHandlers(cfg *config.Config, logger zerolog.Logger, mux *asynq.ServeMux) {
-    mux.HandleFunc(queue.TypePrompt, func(ctx context.Context, t *asynq.Task) error {
+// RegisterHandlers wires the asynq task handlers onto mux. rdb is the
+// process's shared Redis client (see cmd/worker/main.go), reused here for
+// every task's control-channel subscribe, event publish, and replay log
+// append instead of dialing a new client per task.
+func RegisterHandlers(cfg *config.Config, logger zerolog.Logger, mux *asynq.ServeMux, dbh *db.DB, rdb redis.UniversalClient) {
+    mux.HandleFunc(queue.TypePrompt, func(parentCtx context.Context, t *asynq.Task) error {
         var p queue.PromptTask
         if err := json.Unmarshal(t.Payload(), &p); err != nil {
             return err
         }
 
-        // Prepare pubsub channel and publisher
+        sessionID, err := uuid.Parse(p.SessionID)
+        if err != nil {
+            return err
+        }
+
+        // ctx is canceled either by asynq (deadline/server shutdown) or by a
+        // "cancel" control message published for this prompt.
+        ctx, cancel := context.WithCancel(parentCtx)
+        defer cancel()
+
         channel := "claude-code:" + p.SessionID + ":" + p.PromptID
-        rdb := redis.NewClient(&redis.Options{Addr: redisAddrFromURL(cfg.RedisURL)})
-        defer rdb.Close()
+        logKey := channel + ":log"
+        controlChannel := channel + ":control"
+
+        ctrlSub := rdb.Subscribe(ctx, controlChannel)
+        defer ctrlSub.Close()
+        go func() {
+            ctrlCh := ctrlSub.Channel()
+            for {
+                select {
+                case <-ctx.Done():
+                    return
+                case msg, ok := <-ctrlCh:
+                    if !ok {
+                        return
+                    }
+                    if msg.Payload == "cancel" {
+                        cancel()
+                        return
+                    }
+                }
+            }
+        }()
 
-        publish := func(event any) {
-            data, _ := json.Marshal(event)
-            _ = rdb.Publish(ctx, channel, string(data)).Err()
+        // publish is called from both the stdout-scanning loop below and the
+        // stderr-scanning goroutine Runner.Run starts alongside it; serialize
+        // them so the replay log, the pubsub stream, and AppendMessage's seq
+        // assignment all see one event at a time instead of racing.
+        var publishMu sync.Mutex
+        publish := func(ev events.Event) {
+            publishMu.Lock()
+            defer publishMu.Unlock()
+            data, _ := json.Marshal(ev)
+            // Append to the replay log before publishing so a client that
+            // reconnects immediately after the publish still sees the event.
+            _ = rdb.RPush(context.Background(), logKey, string(data)).Err()
+            _ = rdb.Publish(context.Background(), channel, string(data)).Err()
+            if _, err := repo.AppendMessage(context.Background(), dbh, sessionID, p.PromptID, ev); err != nil {
+                logger.Error().Err(err).Msg("persist prompt message failed")
+            }
         }
 
-        publish(ginMsg("message", map[string]any{
-            "type":      "message",
-            "content":   "Initializing Claude runner...",
-            "timestamp": time.Now().UTC().Format(time.RFC3339),
-        }))
+        jobID := p.PromptID
+        if err := repo.UpdateJobState(context.Background(), dbh, sessionID, true, &jobID, nil); err != nil {
+            logger.Error().Err(err).Msg("update job state failed")
+        }
+        defer func() {
+            if err := repo.UpdateJobState(context.Background(), dbh, sessionID, false, nil, nil); err != nil {
+                logger.Error().Err(err).Msg("clear job state failed")
+            }
+        }()
+
+        publish(events.Event{Type: events.KindSystem, System: &events.SystemEvent{Message: "Initializing Claude runner..."}})
+
+        allowedTools := loadAllowedTools(context.Background(), dbh, sessionID, logger)
 
         runner := claude.NewRunner(cfg, logger)
         res := runner.Run(ctx, claude.RunOptions{
-            SessionID:   p.SessionID,
-            PromptID:    p.PromptID,
-            Prompt:      p.Prompt,
-            ProjectPath: p.ProjectPath,
+            SessionID:    p.SessionID,
+            PromptID:     p.PromptID,
+            Prompt:       p.Prompt,
+            ProjectPath:  p.ProjectPath,
+            AllowedTools: allowedTools,
         }, publish)
 
+        if ctx.Err() == context.Canceled {
+            publish(events.Event{Type: events.KindCanceled, Canceled: &events.CanceledEvent{}})
+            status := "canceled"
+            _ = repo.UpdateJobState(context.Background(), dbh, sessionID, false, nil, &status)
+            return asynq.SkipRetry
+        }
+
         if !res.Success {
-            publish(ginMsg("error", map[string]any{
-                "type":      "error",
-                "error":     res.Error,
-                "timestamp": time.Now().UTC().Format(time.RFC3339),
-            }))
+            publish(events.Event{Type: events.KindResult, Result: &events.ResultEvent{Success: false, Error: res.Error}})
+            status := "failed"
+            _ = repo.UpdateJobState(context.Background(), dbh, sessionID, false, nil, &status)
             return asynq.SkipRetry
         }
 
-        publish(ginMsg("result", map[string]any{
-            "type":      "result",
-            "success":   true,
-            "timestamp": time.Now().UTC().Format(time.RFC3339),
-        }))
+        publish(events.Event{Type: events.KindResult, Result: &events.ResultEvent{Success: true}})
+        status := "succeeded"
+        _ = repo.UpdateJobState(context.Background(), dbh, sessionID, false, nil, &status)
         return nil
     })
 }
 
-func ginMsg(typ string, data any) map[string]any { return map[string]any{"type": typ, "data": data} }
-
-func redisAddrFromURL(url string) string {
-    const prefix = "redis://"
-    if len(url) > len(prefix) && url[:len(prefix)] == prefix {
-        return url[len(prefix):]
+// loadAllowedTools reads metadata.allowedTools off the session row. A missing
+// or unparsable field means no restriction.
+func loadAllowedTools(ctx context.Context, dbh *db.DB, sessionID uuid.UUID, logger zerolog.Logger) []string {
+    s, err := repo.GetSession(ctx, dbh, sessionID)
+    if err != nil {
+        logger.Error().Err(err).Msg("load session for allowedTools failed")
+        return nil
     }
-    return url
+    var meta struct {
+        AllowedTools []string `json:"allowedTools"`
+    }
+    if len(s.Metadata) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(s.Metadata, &meta); err != nil {
+        logger.Error().Err(err).Msg("parse session metadata for allowedTools failed")
+        return nil
+    }
+    return meta.AllowedTools
 }
-
@@ -0,0 +1,9 @@
+// Package sql embeds the SQL migration files under sql/migrations so
+// cmd/migrate can ship them inside the compiled binary instead of reading
+// them from disk at runtime.
+package sql
+
+import "embed"
+
+//go:embed migrations
+var MigrationsFS embed.FS